@@ -0,0 +1,93 @@
+package gout
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+var errBindEmptyBody = errors.New("invalid request: empty body")
+
+var (
+	YamlBind     Binding = yamlBinding{}
+	TomlBind     Binding = tomlBinding{}
+	MsgPackBind  Binding = msgpackBinding{}
+	ProtoBufBind Binding = protobufBinding{}
+)
+
+type yamlBinding struct{}
+
+func (yamlBinding) Name() string {
+	return "yaml"
+}
+
+func (yamlBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errBindEmptyBody
+	}
+	if err := yaml.NewDecoder(req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type tomlBinding struct{}
+
+func (tomlBinding) Name() string {
+	return "toml"
+}
+
+func (tomlBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errBindEmptyBody
+	}
+	if err := toml.NewDecoder(req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type msgpackBinding struct{}
+
+func (msgpackBinding) Name() string {
+	return "msgpack"
+}
+
+func (msgpackBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errBindEmptyBody
+	}
+	if err := codec.NewDecoder(req.Body, new(codec.MsgpackHandle)).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type protobufBinding struct{}
+
+func (protobufBinding) Name() string {
+	return "protobuf"
+}
+
+func (protobufBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errBindEmptyBody
+	}
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return errors.New("obj is not proto.Message")
+	}
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(buf, msg); err != nil {
+		return err
+	}
+	return validate(obj)
+}
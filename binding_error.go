@@ -0,0 +1,225 @@
+package gout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+// 绑定数据来源
+const (
+	SourceQuery  = "query"
+	SourceForm   = "form"
+	SourceJSON   = "json"
+	SourceURI    = "uri"
+	SourceHeader = "header"
+	SourceCookie = "cookie"
+)
+
+var (
+	errJSONUnknownField = errors.New("unknown field")
+	errJSONTrailingData = errors.New("invalid request: unexpected data after JSON value")
+)
+
+// BindingError 单个字段的绑定错误
+type BindingError struct {
+	Source string // 数据来源, 如 query, form, json
+	Field  string // 字段路径, 如 items[2].price
+	Value  string // 无法转换的原始值
+	Type   string // 期望的类型, 未知字段等错误为空
+	Err    error
+}
+
+func (e *BindingError) Error() string {
+	reason := e.Err
+	var numErr *strconv.NumError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(reason, &numErr) {
+		reason = numErr.Err
+	} else if errors.As(reason, &typeErr) {
+		// json 的错误信息与字段信息重复
+		reason = nil
+	}
+
+	msg := fmt.Sprintf("%s: cannot bind %q as %s", e.Field, e.Value, e.Type)
+	if e.Type == "" {
+		msg = e.Field
+	}
+	if e.Source != "" {
+		msg = e.Source + " " + msg
+	}
+	if reason != nil {
+		msg += ": " + reason.Error()
+	}
+	return msg
+}
+
+func (e *BindingError) Unwrap() error {
+	return e.Err
+}
+
+// BindingErrors 一次绑定中所有字段的错误.
+// Unwrap() []error 需要 Go 1.20 及以上才会被 errors.Is/errors.As 使用, 更早的版本请使用 AsBindingErrors.
+type BindingErrors []*BindingError
+
+func (errs BindingErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (errs BindingErrors) Unwrap() []error {
+	list := make([]error, len(errs))
+	for i, e := range errs {
+		list[i] = e
+	}
+	return list
+}
+
+// AsBindingErrors 取出 err 中所有字段的绑定错误, err 可以是 *BindingError, BindingErrors 或包装了它们的错误
+func AsBindingErrors(err error) (BindingErrors, bool) {
+	var errs BindingErrors
+	if errors.As(err, &errs) {
+		return errs, true
+	}
+	var e *BindingError
+	if errors.As(err, &e) {
+		return BindingErrors{e}, true
+	}
+	return nil, false
+}
+
+func newBindingError(field, val string, value reflect.Value, err error) *BindingError {
+	return &BindingError{Field: field, Value: val, Type: value.Type().String(), Err: err}
+}
+
+// errorCollector 包装 setter, 记录字段的绑定错误后继续绑定其余字段
+// 嵌套的 collector 共享同一个 errs, path 为其父字段路径
+type errorCollector struct {
+	setter
+	source string
+	path   string
+	only   string // 非空时只绑定带有该 tag 的字段
+	errs   *BindingErrors
+
+	jsonCodec codec.JSONCodec // 传给 setter 解码值中的 Json
+}
+
+// accepts 字段是否由该 collector 绑定
+func (s *errorCollector) accepts(field reflect.StructField) bool {
+	if s.only == "" {
+		return true
+	}
+	_, ok := field.Tag.Lookup(s.only)
+	return ok
+}
+
+func (s *errorCollector) TrySet(value reflect.Value, field reflect.StructField, key string, opt setOptions) (bool, error) {
+	if !s.accepts(field) {
+		return false, nil
+	}
+	opt.jsonCodec = s.jsonCodec
+	isSetted, err := s.setter.TrySet(value, field, key, opt)
+	return s.collect(isSetted, err)
+}
+
+// collect 记录绑定错误并吞掉, 其他错误原样返回
+func (s *errorCollector) collect(isSetted bool, err error) (bool, error) {
+	var errs BindingErrors
+	switch e := err.(type) {
+	case *BindingError:
+		errs = BindingErrors{e}
+	case BindingErrors:
+		errs = e
+	default:
+		return isSetted, err
+	}
+	for _, e := range errs {
+		e.Source = s.source
+		e.Field = joinFieldPath(s.path, e.Field)
+	}
+	*s.errs = append(*s.errs, errs...)
+	return true, nil
+}
+
+func (s *errorCollector) nested(key string) (nestedSetter, bool) {
+	ns, ok := s.setter.(nestedSetter)
+	if !ok {
+		return nil, false
+	}
+	sub, ok := ns.nested(key)
+	if !ok {
+		return nil, false
+	}
+	return &errorCollector{
+		setter:    sub,
+		source:    s.source,
+		path:      joinFieldPath(s.path, key),
+		only:      s.only,
+		errs:      s.errs,
+		jsonCodec: s.jsonCodec,
+	}, true
+}
+
+func (s *errorCollector) keys() []string {
+	if ns, ok := s.setter.(nestedSetter); ok {
+		return ns.keys()
+	}
+	return nil
+}
+
+// mapFormSource 与 mapFormByTag 相同, 但会绑定所有字段, 失败的字段以 BindingErrors 返回
+func mapFormSource(ptr interface{}, form map[string][]string, tag, source string, jsonCodec codec.JSONCodec) error {
+	return mapSource(ptr, formSource(form), tag, source, false, jsonCodec)
+}
+
+// mapSource 从 s 绑定所有字段, 失败的字段以 BindingErrors 返回. tagged 为 true 时只绑定带有 tag 的字段,
+// 值中的 Json 使用 jsonCodec 解码, 为 nil 时使用 codec.JSON
+func mapSource(ptr interface{}, s setter, tag, source string, tagged bool, jsonCodec codec.JSONCodec) error {
+	var errs BindingErrors
+	collector := &errorCollector{setter: s, source: source, errs: &errs, jsonCodec: jsonCodec}
+	if tagged {
+		collector.only = tag
+	}
+	if _, err := mapValue(reflect.ValueOf(ptr), emptyField, collector, tag); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// jsonBindingError 将 json 类型错误和未知字段错误转换为 BindingError, jsonCodec 为解码使用的编解码器.
+// 编解码器未实现 codec.TypeErrorReporter 时只识别 encoding/json 的类型错误
+func jsonBindingError(err error, jsonCodec codec.JSONCodec) error {
+	if reporter, ok := jsonCodec.(codec.UnknownFieldReporter); ok {
+		if field, ok := reporter.UnknownField(err); ok {
+			return &BindingError{Source: SourceJSON, Field: field, Err: errJSONUnknownField}
+		}
+	}
+	if reporter, ok := jsonCodec.(codec.TypeErrorReporter); ok {
+		if field, value, typ, ok := reporter.TypeError(err); ok {
+			return &BindingError{Source: SourceJSON, Field: field, Value: value, Type: typ, Err: err}
+		}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &BindingError{
+			Source: SourceJSON,
+			Field:  typeErr.Field,
+			Value:  typeErr.Value,
+			Type:   typeErr.Type.String(),
+			Err:    err,
+		}
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package gout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+var (
+	ErrFileTooLarge = errors.New("file too large")
+	ErrFileType     = errors.New("file type not allowed")
+	ErrFileCount    = errors.New("too many files")
+)
+
+// sniffLen http.DetectContentType 最多读取的字节数
+const sniffLen = 512
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// multipartBinding 支持 file tag 校验规则的 multipart 绑定, Context.Bind 和 BindFormMultipart 对
+// FormMultipartBind 使用它. maxMemory 为解析时内存中保留的最大字节数, 0 使用 defaultMemory, jsonCodec 为 nil 时使用 codec.JSON.
+// Context 绑定时使用 Engine.MaxMultipartMemory 和 Engine.JSONCodec
+type multipartBinding struct {
+	maxMemory int64
+	jsonCodec codec.JSONCodec
+}
+
+func (multipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+func (b multipartBinding) Bind(req *http.Request, obj interface{}) error {
+	maxMemory := b.maxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMemory
+	}
+	if err := req.ParseMultipartForm(maxMemory); err != nil {
+		return err
+	}
+	if err := mapSource(obj, multipartSource{req.MultipartForm}, "form", SourceForm, false, b.jsonCodec); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// multipartSource 按 form tag 绑定 *multipart.FileHeader 和 []*multipart.FileHeader 字段, 其余字段按普通表单绑定.
+// 文件字段可以用 file tag 设置校验规则, 如 file:"max_size=2MB,types=image/png|image/*,max_count=3",
+// types 按文件内容检测, 不信任客户端提供的 Content-Type.
+type multipartSource struct {
+	form *multipart.Form
+}
+
+var _ nestedSetter = multipartSource{}
+
+func (ms multipartSource) TrySet(value reflect.Value, field reflect.StructField, key string, opt setOptions) (bool, error) {
+	if isFileType(value.Type()) {
+		// 没有上传文件时不绑定, 不能由文本值填充
+		files := ms.form.File[key]
+		if len(files) == 0 {
+			return false, nil
+		}
+		return setFiles(value, field, key, files)
+	}
+	return setByForm(value, field, ms.form.Value, key, opt)
+}
+
+func (ms multipartSource) nested(key string) (nestedSetter, bool) {
+	_, hasValue := ms.form.Value[key]
+	_, hasFile := ms.form.File[key]
+	if hasValue || hasFile {
+		return nil, false
+	}
+
+	sub := &multipart.Form{
+		Value: make(map[string][]string),
+		File:  make(map[string][]*multipart.FileHeader),
+	}
+	for k, vs := range ms.form.Value {
+		if rest, ok := subKey(k, key); ok {
+			sub.Value[rest] = append(sub.Value[rest], vs...)
+		}
+	}
+	for k, files := range ms.form.File {
+		if rest, ok := subKey(k, key); ok {
+			sub.File[rest] = append(sub.File[rest], files...)
+		}
+	}
+	if len(sub.Value)+len(sub.File) == 0 {
+		return nil, false
+	}
+	return multipartSource{sub}, true
+}
+
+func (ms multipartSource) keys() []string {
+	seen := make(map[string]bool, len(ms.form.Value)+len(ms.form.File))
+	keys := make([]string, 0, len(seen))
+	for k := range ms.form.Value {
+		keys = appendFirstSegment(keys, seen, k)
+	}
+	for k := range ms.form.File {
+		keys = appendFirstSegment(keys, seen, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isFileType t 是否为 *multipart.FileHeader, multipart.FileHeader 或 []*multipart.FileHeader
+func isFileType(t reflect.Type) bool {
+	return t == fileHeaderType || t == fileHeaderType.Elem() || t == fileHeaderSliceType
+}
+
+// fileRules file tag 中的校验规则
+type fileRules struct {
+	maxSize  int64
+	types    []string
+	maxCount int
+}
+
+func parseFileRules(field reflect.StructField) (rules fileRules, err error) {
+	opts := field.Tag.Get("file")
+	for len(opts) > 0 {
+		var opt string
+		opt, opts = head(opts, ",")
+		k, v := head(opt, "=")
+		switch strings.TrimSpace(k) {
+		case "max_size":
+			rules.maxSize, err = parseByteSize(v)
+		case "types":
+			rules.types = strings.Split(v, "|")
+		case "max_count":
+			rules.maxCount, err = strconv.Atoi(v)
+		case "":
+		default:
+			err = fmt.Errorf("unknown file rule %q", k)
+		}
+		if err != nil {
+			return rules, fmt.Errorf("invalid file tag on field %s: %w", field.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// parseByteSize 解析 512, 100KB, 2MB, 1GB 这样的大小, 单位为 1024
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	}
+	multiplier := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// setFiles 设置文件字段, value 为 isFileType 中的类型
+func setFiles(value reflect.Value, field reflect.StructField, key string, files []*multipart.FileHeader) (bool, error) {
+	if value.Kind() != reflect.Slice {
+		files = files[:1]
+	}
+
+	rules, err := parseFileRules(field)
+	if err != nil {
+		return true, err
+	}
+	if rules.maxCount > 0 && len(files) > rules.maxCount {
+		return true, &BindingError{Field: key, Value: strconv.Itoa(len(files)), Type: value.Type().String(), Err: ErrFileCount}
+	}
+
+	var errs BindingErrors
+	for i, fh := range files {
+		if err = checkFile(fh, rules); err != nil {
+			name := key
+			if value.Kind() == reflect.Slice {
+				name = fmt.Sprintf("%s[%d]", key, i)
+			}
+			errs = append(errs, &BindingError{Field: name, Value: fh.Filename, Type: "file", Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return true, errs
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		value.Set(reflect.ValueOf(files))
+	case reflect.Ptr:
+		value.Set(reflect.ValueOf(files[0]))
+	default:
+		value.Set(reflect.ValueOf(*files[0]))
+	}
+	return true, nil
+}
+
+func checkFile(fh *multipart.FileHeader, rules fileRules) error {
+	if rules.maxSize > 0 && fh.Size > rules.maxSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrFileTooLarge, fh.Size, rules.maxSize)
+	}
+	if len(rules.types) == 0 {
+		return nil
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	mimeType, _ := head(http.DetectContentType(buf[:n]), ";")
+	if !mimeAllowed(mimeType, rules.types) {
+		return fmt.Errorf("%w: %s", ErrFileType, mimeType)
+	}
+	return nil
+}
+
+// mimeAllowed 支持 image/* 形式的通配
+func mimeAllowed(mimeType string, allowed []string) bool {
+	for _, t := range allowed {
+		t = strings.TrimSpace(t)
+		if t == mimeType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mimeType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package gout
+
+import (
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+var (
+	HeaderBind Binding = headerBinding{}
+	CookieBind Binding = cookieBinding{}
+)
+
+// headerBinding jsonCodec 为 nil 时使用 codec.JSON, Context 绑定时使用 Engine.JSONCodec
+type headerBinding struct {
+	jsonCodec codec.JSONCodec
+}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (b headerBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := mapSource(obj, headerSource(req.Header), "header", SourceHeader, false, b.jsonCodec); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// cookieBinding jsonCodec 为 nil 时使用 codec.JSON, Context 绑定时使用 Engine.JSONCodec
+type cookieBinding struct {
+	jsonCodec codec.JSONCodec
+}
+
+func (cookieBinding) Name() string {
+	return "cookie"
+}
+
+func (b cookieBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := mapSource(obj, formSource(cookieValues(req)), "cookie", SourceCookie, false, b.jsonCodec); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// headerSource 按规范化的 header 名称查找, 如 x-request-id 对应 X-Request-Id
+type headerSource map[string][]string
+
+var _ setter = headerSource(nil)
+
+func (hs headerSource) TrySet(value reflect.Value, field reflect.StructField, tagValue string, opt setOptions) (bool, error) {
+	return setByForm(value, field, hs, textproto.CanonicalMIMEHeaderKey(tagValue), opt)
+}
+
+// cookieValues 请求中所有 cookie 的值, 与 Context.Cookie 一样做 url 解码
+func cookieValues(req *http.Request) map[string][]string {
+	values := make(map[string][]string)
+	for _, cookie := range req.Cookies() {
+		val, err := url.QueryUnescape(cookie.Value)
+		if err != nil {
+			val = cookie.Value
+		}
+		values[cookie.Name] = append(values[cookie.Name], val)
+	}
+	return values
+}
+
+// paramValues 路由参数转换为表单形式
+func paramValues(params ParamMap) map[string][]string {
+	values := make(map[string][]string, len(params))
+	for k, v := range params {
+		values[k] = []string{v}
+	}
+	return values
+}
@@ -0,0 +1,106 @@
+package gout
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// PlatformCloudflare Cloudflare 传递客户端IP的header
+	PlatformCloudflare = "CF-Connecting-IP"
+	// PlatformGoogleAppEngine Google App Engine 传递客户端IP的header
+	PlatformGoogleAppEngine = "X-Appengine-Remote-Addr"
+)
+
+var defaultRemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// SetTrustedProxies 设置受信任的代理, 支持 IP 或 CIDR, 传入 nil 表示不信任任何代理
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+// isTrustedProxy 判断ip是否为受信任的代理
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeader 从右向左遍历 X-Forwarded-For, 返回第一个不受信任的地址
+func (engine *Engine) validateHeader(header string) (clientIP string, valid bool) {
+	if header == "" {
+		return "", false
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ipStr := strings.TrimSpace(items[i])
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			break
+		}
+
+		if i == 0 || !engine.isTrustedProxy(ip) {
+			return ipStr, true
+		}
+	}
+	return "", false
+}
+
+// ClientIP 获取客户端真实IP, 仅当请求来自受信任的代理时才读取 RemoteIPHeaders
+func (c *Context) ClientIP() string {
+	engine := c.Engine
+	if engine.TrustedPlatform != "" {
+		if addr := c.GetHeader(engine.TrustedPlatform); addr != "" {
+			return addr
+		}
+	}
+
+	remoteIP := net.ParseIP(c.RemoteIP())
+	if remoteIP == nil {
+		return ""
+	}
+
+	if engine.isTrustedProxy(remoteIP) {
+		for _, headerName := range engine.RemoteIPHeaders {
+			// 同名 header 可能有多行, 按顺序合并后即为完整的代理链
+			ip, valid := engine.validateHeader(strings.Join(c.Req.Header.Values(headerName), ","))
+			if valid {
+				return ip
+			}
+		}
+	}
+	return remoteIP.String()
+}
+
+// RemoteIP 获取 TCP 连接的对端IP
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return ip
+}
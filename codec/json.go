@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONCodec gout 中 JSON 编解码使用的接口, 可以替换为更快的实现
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// JSONEncoder 与 encoding/json.Encoder 的方法保持一致
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetEscapeHTML(on bool)
+	SetIndent(prefix, indent string)
+}
+
+// JSONDecoder 与 encoding/json.Decoder 的方法保持一致
+type JSONDecoder interface {
+	Decode(v interface{}) error
+	UseNumber()
+	DisallowUnknownFields()
+	More() bool
+	Buffered() io.Reader
+}
+
+// UnknownFieldReporter JSONCodec 的可选接口, 从 DisallowUnknownFields 产生的错误中取出字段名.
+// 未实现时未知字段的错误原样返回, 不会转换为 gout 的 BindingError.
+type UnknownFieldReporter interface {
+	UnknownField(err error) (field string, ok bool)
+}
+
+// TypeErrorReporter JSONCodec 的可选接口, 从类型不匹配的解码错误中取出字段路径, Json 值和目标类型.
+// 未实现时只识别 encoding/json 的 *json.UnmarshalTypeError.
+type TypeErrorReporter interface {
+	TypeError(err error) (field, value, typ string, ok bool)
+}
+
+// JSON 默认的 JSON 编解码器, 默认为 encoding/json. Engine 可以用 WrapOptionJSONCodec 单独设置
+var JSON JSONCodec = StdJSON{}
+
+// StdJSON 基于 encoding/json 的 JSONCodec
+type StdJSON struct{}
+
+func (StdJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (StdJSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (StdJSON) NewEncoder(w io.Writer) JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+func (StdJSON) NewDecoder(r io.Reader) JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+func (StdJSON) TypeError(err error) (field, value, typ string, ok bool) {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return "", "", "", false
+	}
+	return typeErr.Field, typeErr.Value, typeErr.Type.String(), true
+}
+
+// UnknownField encoding/json 的未知字段错误没有导出类型, 只能从错误信息中解析字段名
+func (StdJSON) UnknownField(err error) (string, bool) {
+	field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+	if field == err.Error() {
+		return "", false
+	}
+	if name, uerr := strconv.Unquote(field); uerr == nil {
+		field = name
+	}
+	return field, true
+}
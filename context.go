@@ -1,22 +1,37 @@
 package gout
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/eicesoft/gout/render"
 	"io"
+	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 )
 
 const abortIndex int8 = math.MaxInt8 / 2
 const defaultMemory = 32 << 20
 
+const InternalError500 = "500 INTERNAL SERVER ERROR: %s\n"
+
 const (
 	MIMEJson              = "application/json"
+	MIMEHTML              = "text/html"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPlain             = "text/plain"
+	MIMEYAML              = "application/x-yaml"
+	MIMEYAML2             = "application/yaml"
+	MIMETOML              = "application/toml"
+	MIMEMsgPack           = "application/msgpack"
+	MIMEMsgPack2          = "application/x-msgpack"
+	MIMEProtoBuf          = "application/x-protobuf"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
 	MIMEMultipartPOSTForm = "multipart/form-data"
 )
 
@@ -39,6 +54,10 @@ type Context struct {
 	Params     ParamMap       // Params 请求参数
 	StatusCode int            //响应状态码
 	Engine     *Engine        //服务器引擎
+	Errors     []error        // Errors 请求处理过程中的错误, 如渲染失败
+
+	queryCache url.Values // queryCache 缓存解析后的url查询参数
+	formCache  url.Values // formCache 缓存解析后的表单参数
 }
 
 // NewContext 构建上下文实例
@@ -97,6 +116,10 @@ func (c *Context) reset() {
 	c.index = -1
 	c.value.reset()
 	c.Path = ""
+	c.StatusCode = defaultStatus
+	c.Errors = c.Errors[:0]
+	c.queryCache = nil
+	c.formCache = nil
 }
 
 func (c *Context) init(w http.ResponseWriter, req *http.Request) {
@@ -120,11 +143,26 @@ func (c *Context) Param(key string) string {
 	return value
 }
 
+// JsonParse 解析 Json 请求体, 解码选项见 Engine.EnableDecoderXXX, BindJson 和 Bind 也使用它解析
 func (c *Context) JsonParse(obj interface{}) error {
-	decoder := json.NewDecoder(c.Req.Body)
+	jsonCodec := c.Engine.jsonCodec()
+	decoder := jsonCodec.NewDecoder(c.Req.Body)
+	if c.Engine.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if c.Engine.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
 
 	if err := decoder.Decode(obj); err != nil {
-		return err
+		return jsonBindingError(err, jsonCodec)
+	}
+
+	if c.Engine.EnableDecoderStrict {
+		var extra interface{}
+		if err := decoder.Decode(&extra); err != io.EOF {
+			return errJSONTrailingData
+		}
 	}
 
 	return nil
@@ -175,19 +213,148 @@ func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error
 	return err
 }
 
+func (c *Context) initQueryCache() {
+	if c.queryCache == nil {
+		if c.Req != nil {
+			c.queryCache = c.Req.URL.Query()
+		} else {
+			c.queryCache = url.Values{}
+		}
+	}
+}
+
+func (c *Context) initFormCache() {
+	if c.formCache == nil {
+		req := c.Req
+		if err := req.ParseMultipartForm(c.Engine.MaxMultipartMemory); err != nil {
+			if err != http.ErrNotMultipart {
+				log.Printf("error on parse multipart form array: %v", err)
+			}
+		}
+		c.formCache = req.PostForm
+		if c.formCache == nil {
+			c.formCache = make(url.Values)
+		}
+	}
+}
+
 // Query 获取url的查询参数
 func (c *Context) Query(name string) string {
-	return c.Req.URL.Query().Get(name)
+	value, _ := c.GetQuery(name)
+	return value
+}
+
+// DefaultQuery 获取url的查询参数, 不存在时返回默认值
+func (c *Context) DefaultQuery(key, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetQuery 获取url的查询参数, 并返回参数是否存在
+func (c *Context) GetQuery(key string) (string, bool) {
+	if values, ok := c.GetQueryArray(key); ok {
+		return values[0], ok
+	}
+	return "", false
+}
+
+// QueryArray 获取url查询参数的所有值
+func (c *Context) QueryArray(key string) []string {
+	values, _ := c.GetQueryArray(key)
+	return values
 }
 
-// PostForm 获取表单参数
+// GetQueryArray 获取url查询参数的所有值, 并返回参数是否存在
+func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	c.initQueryCache()
+	values, ok := c.queryCache[key]
+	return values, ok && len(values) > 0
+}
+
+// QueryMap 获取 key[sub]=value 形式的查询参数
+func (c *Context) QueryMap(key string) map[string]string {
+	dicts, _ := c.GetQueryMap(key)
+	return dicts
+}
+
+// GetQueryMap 获取 key[sub]=value 形式的查询参数, 并返回参数是否存在
+func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	c.initQueryCache()
+	return get(c.queryCache, key)
+}
+
+// PostForm 获取表单参数, 请求体中不存在时读取查询参数, 与 Req.FormValue 一致.
+// 只读取请求体使用 GetPostForm
 func (c *Context) PostForm(key string) string {
-	return c.Req.FormValue(key)
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	value, _ := c.GetQuery(key)
+	return value
 }
 
-// Status 设置状态码
+// DefaultPostForm 获取表单参数, 不存在时返回默认值
+func (c *Context) DefaultPostForm(key, defaultValue string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetPostForm 获取表单参数, 并返回参数是否存在
+func (c *Context) GetPostForm(key string) (string, bool) {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values[0], ok
+	}
+	return "", false
+}
+
+// PostFormArray 获取表单参数的所有值
+func (c *Context) PostFormArray(key string) []string {
+	values, _ := c.GetPostFormArray(key)
+	return values
+}
+
+// GetPostFormArray 获取表单参数的所有值, 并返回参数是否存在
+func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	c.initFormCache()
+	values, ok := c.formCache[key]
+	return values, ok && len(values) > 0
+}
+
+// PostFormMap 获取 key[sub]=value 形式的表单参数
+func (c *Context) PostFormMap(key string) map[string]string {
+	dicts, _ := c.GetPostFormMap(key)
+	return dicts
+}
+
+// GetPostFormMap 获取 key[sub]=value 形式的表单参数, 并返回参数是否存在
+func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	c.initFormCache()
+	return get(c.formCache, key)
+}
+
+// get 从 m 中取出 key[sub] 形式的参数
+func get(m map[string][]string, key string) (map[string]string, bool) {
+	dicts := make(map[string]string)
+	exist := false
+	for k, v := range m {
+		if i := strings.IndexByte(k, '['); i >= 1 && k[0:i] == key {
+			if j := strings.IndexByte(k[i+1:], ']'); j >= 1 {
+				exist = true
+				dicts[k[i+1:][:j]] = v[0]
+			}
+		}
+	}
+	return dicts, exist
+}
+
+// Status 设置状态码, 同时写入 Writer, 响应写出前调用才生效
 func (c *Context) Status(code int) {
 	c.StatusCode = code
+	c.Writer.WriteHeader(code)
 }
 
 // SetHeader 设置header
@@ -217,10 +384,26 @@ func (c *Context) Render(code int, r render.Render) {
 	}
 
 	if err := r.Render(c.Writer); err != nil {
-		panic(err)
+		_ = c.Error(err)
+		if !c.Writer.Written() {
+			// 尚未写入响应时改为返回 500, 丢弃渲染器设置的 Content-Type
+			c.Writer.Header().Del("Content-Type")
+			c.index = abortIndex
+			c.String(http.StatusInternalServerError, InternalError500, c.Path)
+			return
+		}
+		if hook := c.Engine.RenderErrorHook; hook != nil {
+			hook(c, err)
+		}
 	}
 }
 
+// Error 记录请求处理过程中的错误
+func (c *Context) Error(err error) error {
+	c.Errors = append(c.Errors, err)
+	return err
+}
+
 // String 返回字符串
 func (c *Context) String(code int, format string, values ...interface{}) {
 	c.Render(code, render.Text{Data: fmt.Sprintf(format, values...)})
@@ -228,7 +411,32 @@ func (c *Context) String(code int, format string, values ...interface{}) {
 
 // JSON 返回Json数据
 func (c *Context) JSON(code int, obj interface{}) {
-	c.Render(code, render.JSON{Data: obj})
+	c.Render(code, render.JSON{Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// IndentedJSON 返回缩进格式的Json数据, 用于调试
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, render.IndentedJSON{Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// SecureJSON 返回Json数据, 数组前加上 Engine.SecureJSONPrefix 防止 JSON 劫持
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, render.SecureJSON{Prefix: c.Engine.SecureJSONPrefix, Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// JSONP 返回JSONP数据, 回调名取自查询参数 callback
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, render.JSONP{Callback: c.Query("callback"), Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// AsciiJSON 返回Json数据, 非 ASCII 字符转义为 \uXXXX
+func (c *Context) AsciiJSON(code int, obj interface{}) {
+	c.Render(code, render.AsciiJSON{Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// PureJSON 返回Json数据, 不转义 HTML 字符
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, render.PureJSON{Data: obj, Codec: c.Engine.jsonCodec()})
 }
 
 // Raw 返回字节流数据
@@ -242,13 +450,66 @@ func (c *Context) Html(code int, html string) {
 }
 
 func (c *Context) Template(code int, filename string, values interface{}) {
-	c.Render(code, render.Template{Data: values, Filename: filename})
+	c.Render(code, render.Template{Data: values, Filename: filename, Debug: c.Engine.DevMode})
 }
 
 func (c *Context) Xml(code int, xml interface{}) {
 	c.Render(code, render.XML{Data: xml})
 }
 
+// YAML 返回YAML数据
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, render.YAML{Data: obj})
+}
+
+// TOML 返回TOML数据
+func (c *Context) TOML(code int, obj interface{}) {
+	c.Render(code, render.TOML{Data: obj})
+}
+
+// MsgPack 返回MessagePack数据
+func (c *Context) MsgPack(code int, obj interface{}) {
+	c.Render(code, render.MsgPack{Data: obj})
+}
+
+// CSVOption Context.CSV 的输出配置
+type CSVOption func(*render.CSV)
+
+// CSVComma 设置分隔符, 如 ';'
+func CSVComma(comma rune) CSVOption {
+	return func(r *render.CSV) { r.Comma = comma }
+}
+
+// CSVBOM 是否输出 UTF-8 BOM, Excel 需要它来识别编码
+func CSVBOM(enable bool) CSVOption {
+	return func(r *render.CSV) { r.BOM = enable }
+}
+
+// CSVEscape 是否转义公式单元格, 覆盖 Engine.CSVEscapeFormulas
+func CSVEscape(enable bool) CSVOption {
+	return func(r *render.CSV) { r.EscapeFormulas = enable }
+}
+
+// CSV 返回CSV数据, data 为结构体切片, 可以通过 opts 设置分隔符, BOM 和公式转义
+func (c *Context) CSV(code int, data interface{}, opts ...CSVOption) {
+	r := render.CSV{Data: data, EscapeFormulas: c.Engine.CSVEscapeFormulas}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	c.Render(code, r)
+}
+
+// CSVAttachment 以附件形式返回CSV数据, filename 为客户端保存的文件名
+func (c *Context) CSVAttachment(code int, filename string, data interface{}, opts ...CSVOption) {
+	c.Attachment(filename)
+	c.CSV(code, data, opts...)
+}
+
+// ProtoBuf 返回Protocol Buffers数据, obj 必须实现 proto.Message
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, render.ProtoBuf{Data: obj})
+}
+
 func (c *Context) Redirect(code int, location string) {
 	c.Render(-1, render.Redirect{Code: code, Request: c.Req, Location: location})
 }
@@ -288,8 +549,23 @@ func (c *Context) ContentType() string {
 }
 
 func (c *Context) Bind(obj interface{}) error {
-	b := Default(c.Req.Method, c.ContentType())
-	return c.mustBindWith(obj, b)
+	switch b := Default(c.Req.Method, c.ContentType()); b {
+	case QueryBind:
+		return c.BindQuery(obj)
+	case JsonBind:
+		return c.BindJson(obj)
+	case FormBind:
+		return c.BindForm(obj)
+	case FormMultipartBind:
+		return c.BindFormMultipart(obj)
+	default:
+		return c.mustBindWith(obj, b)
+	}
+}
+
+// multipartBinding 使用 Engine.MaxMultipartMemory 的 multipart 绑定
+func (c *Context) multipartBinding() Binding {
+	return multipartBinding{maxMemory: c.Engine.MaxMultipartMemory, jsonCodec: c.Engine.jsonCodec()}
 }
 
 func Default(method, contentType string) Binding {
@@ -301,28 +577,157 @@ func Default(method, contentType string) Binding {
 			return JsonBind
 		case MIMEMultipartPOSTForm:
 			return FormMultipartBind
+		case MIMEYAML, MIMEYAML2:
+			return YamlBind
+		case MIMETOML:
+			return TomlBind
+		case MIMEMsgPack, MIMEMsgPack2:
+			return MsgPackBind
+		case MIMEProtoBuf:
+			return ProtoBufBind
 		default:
 			return FormBind
 		}
 	}
 }
 
+// BindForm 绑定查询参数和表单参数, 所有字段的错误以 BindingErrors 返回
 func (c *Context) BindForm(obj interface{}) error {
-	return c.mustBindWith(obj, FormBind)
+	c.initFormCache()
+	form := c.Req.Form
+	if form == nil {
+		c.initQueryCache()
+		form = c.queryCache
+	}
+	return c.bindFormSource(obj, form, SourceForm)
 }
 
+// BindJson 绑定 Json 请求体, 使用 Engine 的解码选项
 func (c *Context) BindJson(obj interface{}) error {
-	return c.mustBindWith(obj, JsonBind)
+	if c.Req == nil || c.Req.Body == nil {
+		return errBindEmptyBody
+	}
+	if err := c.JsonParse(obj); err != nil {
+		return err
+	}
+	return validate(obj)
 }
 
+// BindQuery 绑定查询参数, 所有字段的错误以 BindingErrors 返回
 func (c *Context) BindQuery(obj interface{}) error {
-	return c.mustBindWith(obj, QueryBind)
+	c.initQueryCache()
+	return c.bindFormSource(obj, c.queryCache, SourceQuery)
 }
 
+// BindFormPost 只绑定请求体中的表单参数, 所有字段的错误以 BindingErrors 返回
 func (c *Context) BindFormPost(obj interface{}) error {
-	return c.mustBindWith(obj, FormPostBind)
+	c.initFormCache()
+	return c.bindFormSource(obj, c.formCache, SourceForm)
+}
+
+func (c *Context) bindFormSource(obj interface{}, form map[string][]string, source string) error {
+	if err := mapFormSource(obj, form, "form", source, c.Engine.jsonCodec()); err != nil {
+		return err
+	}
+	return validate(obj)
 }
 
+// BindFormMultipart 绑定 multipart 表单和上传的文件, 文件字段支持 file tag 校验规则, 见 multipartSource
 func (c *Context) BindFormMultipart(obj interface{}) error {
-	return c.mustBindWith(obj, FormMultipartBind)
+	return c.mustBindWith(obj, c.multipartBinding())
+}
+
+func (c *Context) BindYaml(obj interface{}) error {
+	return c.mustBindWith(obj, YamlBind)
+}
+
+func (c *Context) BindToml(obj interface{}) error {
+	return c.mustBindWith(obj, TomlBind)
+}
+
+func (c *Context) BindMsgPack(obj interface{}) error {
+	return c.mustBindWith(obj, MsgPackBind)
+}
+
+func (c *Context) BindProtoBuf(obj interface{}) error {
+	return c.mustBindWith(obj, ProtoBufBind)
+}
+
+// BindUri 按 uri tag 绑定路由参数
+func (c *Context) BindUri(obj interface{}) error {
+	if err := mapSource(obj, formSource(paramValues(c.Params)), "uri", SourceURI, false, c.Engine.jsonCodec()); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// BindHeader 按 header tag 绑定请求头, tag 中的名称不区分大小写
+func (c *Context) BindHeader(obj interface{}) error {
+	return c.mustBindWith(obj, headerBinding{jsonCodec: c.Engine.jsonCodec()})
+}
+
+// BindCookie 按 cookie tag 绑定 cookie
+func (c *Context) BindCookie(obj interface{}) error {
+	return c.mustBindWith(obj, cookieBinding{jsonCodec: c.Engine.jsonCodec()})
+}
+
+// BindAll 依次从路由参数(uri tag), 查询参数(form tag), 请求头(header tag)和请求体绑定同一个结构体.
+// 前三者只绑定带有对应 tag 的字段, 所有来源的字段错误合并为一个 BindingErrors 返回.
+func (c *Context) BindAll(obj interface{}) error {
+	var errs BindingErrors
+	collect := func(err error) error {
+		switch e := err.(type) {
+		case *BindingError:
+			errs = append(errs, e)
+		case BindingErrors:
+			errs = append(errs, e...)
+		default:
+			return err
+		}
+		return nil
+	}
+
+	c.initQueryCache()
+	sources := []struct {
+		setter setter
+		tag    string
+		source string
+	}{
+		{formSource(paramValues(c.Params)), "uri", SourceURI},
+		{formSource(c.queryCache), "form", SourceQuery},
+		{headerSource(c.Req.Header), "header", SourceHeader},
+	}
+	for _, s := range sources {
+		if err := collect(mapSource(obj, s.setter, s.tag, s.source, true, c.Engine.jsonCodec())); err != nil {
+			return err
+		}
+	}
+
+	if err := collect(c.bindBody(obj)); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return validate(obj)
+}
+
+// bindBody 按 Content-Type 绑定请求体, 没有请求体时直接返回
+func (c *Context) bindBody(obj interface{}) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody || c.Req.Method == http.MethodGet {
+		return nil
+	}
+
+	switch c.ContentType() {
+	case MIMEJson:
+		return c.JsonParse(obj)
+	case MIMEPOSTForm, MIMEMultipartPOSTForm:
+		c.initFormCache()
+		if c.Req.MultipartForm != nil {
+			return mapSource(obj, multipartSource{c.Req.MultipartForm}, "form", SourceForm, true, c.Engine.jsonCodec())
+		}
+		return mapSource(obj, formSource(c.formCache), "form", SourceForm, true, c.Engine.jsonCodec())
+	default:
+		return Default(c.Req.Method, c.ContentType()).Bind(c.Req, obj)
+	}
 }
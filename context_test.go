@@ -0,0 +1,37 @@
+package gout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBindAllNestedOnlyTagged(t *testing.T) {
+	type inner struct {
+		Name     string `form:"name"`
+		Untagged string
+	}
+	type request struct {
+		ID    string `uri:"id"`
+		Inner inner  `form:"inner"`
+	}
+
+	engine := NewServer()
+	var got request
+	var bindErr error
+	engine.GET("/items/:id", func(c *Context) {
+		bindErr = c.BindAll(&got)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/items/7?inner.name=a&inner.Untagged=b&inner[Untagged]=c", nil)
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if bindErr != nil {
+		t.Fatalf("BindAll() error = %v", bindErr)
+	}
+	if got.ID != "7" || got.Inner.Name != "a" {
+		t.Fatalf("BindAll() = %+v, want ID 7 and Inner.Name a", got)
+	}
+	if got.Inner.Untagged != "" {
+		t.Fatalf("untagged nested field bound from query: %q", got.Inner.Untagged)
+	}
+}
@@ -0,0 +1,95 @@
+package gout
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// BindUnmarshaler 自定义类型从表单, query 等字符串参数绑定时实现该接口
+type BindUnmarshaler interface {
+	UnmarshalParam(param string) error
+}
+
+// Converter 将字符串参数转换为注册的类型
+type Converter func(value string) (interface{}, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = make(map[reflect.Type]Converter)
+
+	bindUnmarshalerType = reflect.TypeOf((*BindUnmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// RegisterConverter 注册 typ 类型的参数转换函数, 优先于 BindUnmarshaler 和 encoding.TextUnmarshaler.
+// 转换函数是全局的, 对进程中所有 Engine 的绑定生效, 通常在 init 中注册. fn 为 nil 时删除已注册的转换函数.
+func RegisterConverter(typ reflect.Type, fn Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	if fn == nil {
+		delete(converters, typ)
+		return
+	}
+	converters[typ] = fn
+}
+
+func lookupConverter(typ reflect.Type) (Converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	fn, ok := converters[typ]
+	return fn, ok
+}
+
+// hasCustomSetter typ 是否由 Converter, BindUnmarshaler 或 TextUnmarshaler 处理
+func hasCustomSetter(typ reflect.Type) bool {
+	if _, ok := lookupConverter(typ); ok {
+		return true
+	}
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(bindUnmarshalerType) {
+		return true
+	}
+	// time.Time 由 time_format 等 tag 控制, 不走 UnmarshalText
+	return typ != timeType && ptr.Implements(textUnmarshalerType)
+}
+
+// trySetCustom 依次尝试 Converter, BindUnmarshaler 和 TextUnmarshaler, 都不适用时返回 false
+func trySetCustom(val string, value reflect.Value) (bool, error) {
+	typ := value.Type()
+	if fn, ok := lookupConverter(typ); ok {
+		v, err := fn(val)
+		if err != nil {
+			return true, err
+		}
+		rv := reflect.ValueOf(v)
+		switch {
+		case !rv.IsValid():
+			value.Set(reflect.Zero(typ))
+		case rv.Type().AssignableTo(typ):
+			value.Set(rv)
+		case rv.Type().ConvertibleTo(typ):
+			value.Set(rv.Convert(typ))
+		default:
+			return true, fmt.Errorf("converter for %s returned %s", typ, rv.Type())
+		}
+		return true, nil
+	}
+
+	if !value.CanAddr() {
+		return false, nil
+	}
+	switch u := value.Addr().Interface().(type) {
+	case BindUnmarshaler:
+		return true, u.UnmarshalParam(val)
+	case encoding.TextUnmarshaler:
+		if typ == timeType {
+			return false, nil
+		}
+		return true, u.UnmarshalText([]byte(val))
+	}
+	return false, nil
+}
@@ -0,0 +1,228 @@
+package gout
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrCookieInvalid cookie 签名校验或解密失败
+	ErrCookieInvalid = errors.New("gout: invalid cookie value")
+	// ErrCookieNoKeys 未配置 cookie 密钥
+	ErrCookieNoKeys = errors.New("gout: no cookie keys configured")
+)
+
+// CookieOptions cookie 属性, Engine.CookieOptions 作为默认值
+type CookieOptions struct {
+	Path        string
+	Domain      string
+	MaxAge      int
+	SameSite    http.SameSite
+	Secure      bool
+	HttpOnly    bool
+	Partitioned bool // CHIPS, 需要同时设置 Secure
+}
+
+// CookieOption 修改单个 cookie 的属性
+type CookieOption func(*CookieOptions)
+
+// CookiePath 设置 cookie Path
+func CookiePath(path string) CookieOption {
+	return func(o *CookieOptions) { o.Path = path }
+}
+
+// CookieDomain 设置 cookie Domain
+func CookieDomain(domain string) CookieOption {
+	return func(o *CookieOptions) { o.Domain = domain }
+}
+
+// CookieMaxAge 设置 cookie Max-Age, 小于0时删除 cookie
+func CookieMaxAge(maxAge int) CookieOption {
+	return func(o *CookieOptions) { o.MaxAge = maxAge }
+}
+
+// CookieSameSite 设置 cookie SameSite
+func CookieSameSite(sameSite http.SameSite) CookieOption {
+	return func(o *CookieOptions) { o.SameSite = sameSite }
+}
+
+// CookieSecure 设置 cookie Secure
+func CookieSecure(secure bool) CookieOption {
+	return func(o *CookieOptions) { o.Secure = secure }
+}
+
+// CookieHttpOnly 设置 cookie HttpOnly
+func CookieHttpOnly(httpOnly bool) CookieOption {
+	return func(o *CookieOptions) { o.HttpOnly = httpOnly }
+}
+
+// CookiePartitioned 设置 cookie Partitioned
+func CookiePartitioned(partitioned bool) CookieOption {
+	return func(o *CookieOptions) { o.Partitioned = partitioned }
+}
+
+// Cookie 获取请求中的 cookie 值
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	val, _ := url.QueryUnescape(cookie.Value)
+	return val, nil
+}
+
+// SetCookie 设置响应 cookie, 未指定的属性使用 Engine.CookieOptions
+func (c *Context) SetCookie(name, value string, opts ...CookieOption) {
+	o := c.Engine.CookieOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   o.MaxAge,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		SameSite: o.SameSite,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+	}
+	v := cookie.String()
+	if v == "" {
+		return
+	}
+	if o.Partitioned {
+		v += "; Partitioned"
+	}
+	c.Writer.Header().Add("Set-Cookie", v)
+}
+
+// SetSignedCookie 设置 HMAC 签名的 cookie, 使用 Engine.CookieKeys 的第一个密钥签名
+func (c *Context) SetSignedCookie(name, value string, opts ...CookieOption) error {
+	keys := c.Engine.CookieKeys
+	if len(keys) == 0 {
+		return ErrCookieNoKeys
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	sig := signCookie(keys[0], name, payload)
+	c.SetCookie(name, payload+"."+sig, opts...)
+	return nil
+}
+
+// SignedCookie 获取签名 cookie 的值, 依次使用 Engine.CookieKeys 校验以支持密钥轮换
+func (c *Context) SignedCookie(name string) (string, error) {
+	keys := c.Engine.CookieKeys
+	if len(keys) == 0 {
+		return "", ErrCookieNoKeys
+	}
+
+	raw, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	i := strings.LastIndexByte(raw, '.')
+	if i < 0 {
+		return "", ErrCookieInvalid
+	}
+	payload, sig := raw[:i], raw[i+1:]
+
+	for _, key := range keys {
+		if hmac.Equal([]byte(sig), []byte(signCookie(key, name, payload))) {
+			value, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return "", ErrCookieInvalid
+			}
+			return string(value), nil
+		}
+	}
+	return "", ErrCookieInvalid
+}
+
+// SetEncryptedCookie 设置 AES-GCM 加密的 cookie, 使用 Engine.CookieKeys 的第一个密钥加密
+func (c *Context) SetEncryptedCookie(name, value string, opts ...CookieOption) error {
+	keys := c.Engine.CookieKeys
+	if len(keys) == 0 {
+		return ErrCookieNoKeys
+	}
+
+	aead, err := cookieAEAD(keys[0])
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	c.SetCookie(name, base64.RawURLEncoding.EncodeToString(sealed), opts...)
+	return nil
+}
+
+// EncryptedCookie 获取加密 cookie 的值, 依次使用 Engine.CookieKeys 解密以支持密钥轮换
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	keys := c.Engine.CookieKeys
+	if len(keys) == 0 {
+		return "", ErrCookieNoKeys
+	}
+
+	raw, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+
+	for _, key := range keys {
+		aead, err := cookieAEAD(key)
+		if err != nil {
+			return "", err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return "", ErrCookieInvalid
+		}
+		nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if value, err := aead.Open(nil, nonce, data, []byte(name)); err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrCookieInvalid
+}
+
+// deriveCookieKey 由配置的密钥派生出签名和加密各自使用的子密钥
+func deriveCookieKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func signCookie(key []byte, name, payload string) string {
+	mac := hmac.New(sha256.New, deriveCookieKey(key, "gout-cookie-sign"))
+	mac.Write([]byte(name))
+	mac.Write([]byte{'='})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func cookieAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveCookieKey(key, "gout-cookie-encrypt"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,171 @@
+package gout
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/eicesoft/gout/render"
+)
+
+// File 返回本地文件, 支持 Range, If-Modified-Since 和 If-None-Match
+func (c *Context) File(filepath string) {
+	c.serveContent(osFile(filepath), path.Base(filepath), "")
+}
+
+// FileAttachment 以附件形式返回本地文件, downloadName 为客户端保存的文件名
+func (c *Context) FileAttachment(filepath, downloadName string) {
+	c.serveContent(osFile(filepath), path.Base(filepath), downloadName)
+}
+
+// FileFromFS 从 http.FileSystem 中返回文件, 可用于 embed.FS
+func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
+	c.serveContent(fsFile(fs, filepath), path.Base(filepath), "")
+}
+
+type openFunc func() (http.File, error)
+
+func osFile(name string) openFunc {
+	return func() (http.File, error) { return os.Open(name) }
+}
+
+func fsFile(fs http.FileSystem, name string) openFunc {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return func() (http.File, error) { return fs.Open(path.Clean(name)) }
+}
+
+func (c *Context) serveContent(open openFunc, name, downloadName string) {
+	f, err := open()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	if info.IsDir() {
+		c.fileError(os.ErrNotExist)
+		return
+	}
+
+	header := c.Writer.Header()
+	if downloadName != "" {
+		header.Set("Content-Disposition", attachmentDisposition(downloadName))
+	}
+	if _, ok := header["Etag"]; !ok {
+		header.Set("Etag", fileETag(info))
+	}
+	http.ServeContent(c.Writer, c.Req, name, info.ModTime(), f)
+}
+
+func (c *Context) fileError(err error) {
+	switch {
+	case os.IsNotExist(err):
+		c.String(http.StatusNotFound, NoFound404, c.Path)
+	case os.IsPermission(err):
+		c.String(http.StatusForbidden, "403 FORBIDDEN: %s\n", c.Path)
+	default:
+		c.String(http.StatusInternalServerError, InternalError500, c.Path)
+	}
+}
+
+// Attachment 设置 Content-Disposition, 让客户端以 filename 保存响应内容
+func (c *Context) Attachment(filename string) {
+	c.SetHeader("Content-Disposition", attachmentDisposition(filename))
+}
+
+// fileETag 由文件大小和修改时间生成弱 ETag
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// attachmentDisposition 按 RFC 6266 生成 Content-Disposition, 非 ASCII 文件名使用 filename*
+func attachmentDisposition(filename string) string {
+	fallback := make([]rune, 0, len(filename))
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			r = '_'
+		}
+		fallback = append(fallback, r)
+	}
+	if string(fallback) == filename {
+		return `attachment; filename="` + filename + `"`
+	}
+	return `attachment; filename="` + string(fallback) + `"; filename*=UTF-8''` + encodeRFC5987(filename)
+}
+
+func encodeRFC5987(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// DataFromReader 从 reader 返回数据, reader 实现 io.ReadSeeker 时支持 Range.
+// headers 中的 ETag 和 Last-Modified 用于条件请求.
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, headers map[string]string) {
+	header := c.Writer.Header()
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	modtime, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	if rs, ok := reader.(io.ReadSeeker); ok && code == http.StatusOK {
+		http.ServeContent(c.Writer, c.Req, "", modtime, rs)
+		return
+	}
+
+	if notModified(c.Req, header.Get("Etag"), modtime) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Render(code, render.Reader{
+		ContentType:   contentType,
+		ContentLength: contentLength,
+		Reader:        reader,
+	})
+}
+
+// notModified 处理不支持 seek 的内容的 If-None-Match 和 If-Modified-Since
+func notModified(req *http.Request, etag string, modtime time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modtime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modtime.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -1,3 +1,10 @@
 module github.com/eicesoft/gout
 
 go 1.17
+
+require (
+	github.com/pelletier/go-toml/v2 v2.0.6
+	github.com/ugorji/go/codec v1.2.7
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -3,11 +3,16 @@ package gout
 import (
 	"context"
 	"fmt"
+	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/eicesoft/gout/codec"
+	"github.com/eicesoft/gout/render"
 )
 
 // HandlerFunc defines the handler used by middleware.
@@ -18,6 +23,9 @@ type HandlersChain []HandlerFunc
 
 const defaultMultipartMemory = 32 << 21 // 64 MB
 
+// shutdownTimeout http server 关闭和 OnShutdown 回调各自的超时
+const shutdownTimeout = 5 * time.Second
+
 const (
 	GET    = "GET"
 	POST   = "POST"
@@ -26,7 +34,7 @@ const (
 )
 
 var DefaultOption = &Options{
-	false,
+	IsEnablePProf: false,
 }
 
 // Engine 作为最顶层
@@ -38,6 +46,34 @@ type Engine struct {
 	groups             []*RouterGroup // 存储所有的分组
 	pool               sync.Pool
 	MaxMultipartMemory int64 //MaxMultipartMemory
+
+	CookieOptions CookieOptions // cookie 默认属性
+	CookieKeys    [][]byte      // 签名/加密 cookie 的密钥, 第一个用于写入, 全部用于读取
+
+	RemoteIPHeaders []string     // ClientIP 依次读取的header, 仅对受信任的代理生效
+	TrustedPlatform string       // 平台设置的客户端IP header, 如 PlatformCloudflare
+	trustedCIDRs    []*net.IPNet // 受信任的代理
+
+	WebSocket WSConfig // websocket 升级配置
+
+	shutdownHooks []func(ctx context.Context) // 服务关闭时调用
+
+	// RenderErrorHook 响应已部分写入后渲染失败时调用, 默认输出日志
+	RenderErrorHook func(c *Context, err error)
+
+	SecureJSONPrefix  string // SecureJSON 数组响应的前缀
+	CSVEscapeFormulas bool   // Context.CSV 默认是否转义以 =, +, -, @, \t, \r 开头的单元格, 见 render.CSV.EscapeFormulas
+
+	JSONCodec JSONCodec // Json 渲染和 JsonParse 使用的编解码器, 为 nil 时使用 codec.JSON
+
+	EnableDecoderDisallowUnknownFields bool // JsonParse 拒绝结构体中不存在的字段
+	EnableDecoderUseNumber             bool // JsonParse 将 interface{} 中的数字解析为 json.Number
+	EnableDecoderStrict                bool // JsonParse 拒绝 JSON 值之后的多余数据
+
+	DevMode    bool               // 开发模式, 模板热加载并以错误页面展示模板错误
+	delims     render.Delims      // 模板分隔符
+	FuncMap    template.FuncMap   // 模板函数
+	HTMLRender *render.HTMLEngine // LoadHTMLGlob/LoadHTMLFS 加载的模板
 }
 
 // RouterGroup 管理各种路由
@@ -61,6 +97,11 @@ func NewServer(opts ...Option) *Engine {
 	engine := &Engine{
 		router:             newRouter(),
 		MaxMultipartMemory: defaultMultipartMemory,
+		RemoteIPHeaders:    append([]string(nil), defaultRemoteIPHeaders...),
+		delims:             render.Delims{Left: "{{", Right: "}}"},
+		FuncMap:            template.FuncMap{},
+		RenderErrorHook:    logRenderError,
+		SecureJSONPrefix:   "while(1);",
 	}
 
 	engine.pool.New = func() interface{} {
@@ -72,6 +113,24 @@ func NewServer(opts ...Option) *Engine {
 	engine.groups = []*RouterGroup{engine.RouterGroup}
 
 	options := newOptions(opts...)
+	if options.CookieOptions != nil {
+		engine.CookieOptions = *options.CookieOptions
+	}
+	engine.CookieKeys = options.CookieKeys
+	if options.TrustedProxies != nil {
+		if err := engine.SetTrustedProxies(options.TrustedProxies); err != nil {
+			panic(err)
+		}
+	}
+	if options.RemoteIPHeaders != nil {
+		engine.RemoteIPHeaders = append([]string(nil), options.RemoteIPHeaders...)
+	}
+	engine.TrustedPlatform = options.TrustedPlatform
+	engine.DevMode = options.IsDevMode
+	engine.EnableDecoderDisallowUnknownFields = options.EnableDecoderDisallowUnknownFields
+	engine.EnableDecoderUseNumber = options.EnableDecoderUseNumber
+	engine.EnableDecoderStrict = options.EnableDecoderStrict
+	engine.JSONCodec = options.JSONCodec
 	if options.IsEnablePProf {
 		log.Printf("* Registry pprof routers - /debug/pprof")
 		WrapPProfHandler(engine)
@@ -80,6 +139,19 @@ func NewServer(opts ...Option) *Engine {
 	return engine
 }
 
+// logRenderError 默认的 RenderErrorHook
+func logRenderError(c *Context, err error) {
+	log.Printf("[WARNING] Render %s %s failed after writing %d bytes: %v", c.Method, c.Path, c.Writer.Size(), err)
+}
+
+// jsonCodec 返回 Engine.JSONCodec, 未设置时使用 codec.JSON
+func (engine *Engine) jsonCodec() codec.JSONCodec {
+	if engine.JSONCodec != nil {
+		return engine.JSONCodec
+	}
+	return codec.JSON
+}
+
 func (engine *Engine) allocateContext() *Context {
 	return &Context{Engine: engine, index: -1, StatusCode: 200, value: &Values{}}
 }
@@ -101,6 +173,7 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c.reset()
 	c.init(w, req)
 	engine.handleRequest(c)
+	c.writermem.WriteHeaderNow()
 
 	engine.pool.Put(c)
 }
@@ -142,6 +215,17 @@ func (group *RouterGroup) PUT(pattern string, handler HandlerFunc) {
 	group.addRoute(http.MethodPut, pattern, handler)
 }
 
+// HEAD HEAD路由
+func (group *RouterGroup) HEAD(pattern string, handler HandlerFunc) {
+	group.addRoute(http.MethodHead, pattern, handler)
+}
+
+// OnShutdown 注册服务关闭时的回调, 在 http server 停止接受新请求后按注册顺序调用, 使用单独的超时.
+// 被接管的连接(如 websocket)不受 http server 关闭的影响, 需要在这里处理.
+func (engine *Engine) OnShutdown(hooks ...func(ctx context.Context)) {
+	engine.shutdownHooks = append(engine.shutdownHooks, hooks...)
+}
+
 // Run Start a http server
 func (engine *Engine) Run(addr string) {
 	log.Printf("Listen in address %s", addr)
@@ -160,10 +244,18 @@ func (engine *Engine) Run(addr string) {
 	}()
 
 	ExitHook().Close(func() { //处理信号进行关闭
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
+		err := engine.server.Shutdown(ctx)
+
+		// server 已不再接受新的连接升级, 回调使用自己的超时, 不受 server 关闭耗时的影响
+		hookCtx, hookCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer hookCancel()
+		for _, hook := range engine.shutdownHooks {
+			hook(hookCtx)
+		}
 
-		if err := engine.server.Shutdown(ctx); err != nil {
+		if err != nil {
 			panic(err)
 		} else {
 			log.Printf("Server is closed.")
@@ -0,0 +1,82 @@
+package gout
+
+import (
+	"context"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eicesoft/gout/render"
+)
+
+// templatePollInterval 开发模式下检查模板文件变化的间隔
+const templatePollInterval = time.Second
+
+// Delims 设置模板分隔符, 需要在 LoadHTMLGlob/LoadHTMLFS 之前调用
+func (engine *Engine) Delims(left, right string) *Engine {
+	engine.delims = render.Delims{Left: left, Right: right}
+	return engine
+}
+
+// SetFuncMap 设置模板函数, 需要在 LoadHTMLGlob/LoadHTMLFS 之前调用
+func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
+	engine.FuncMap = funcMap
+}
+
+// LoadHTMLGlob 解析匹配的模板文件, 模板名为相对于 pattern 中第一个通配符所在目录的路径.
+// 该目录下 layouts/ 和 partials/ 中的模板自动作为共享模板加载.
+//
+//	engine.LoadHTMLGlob("templates/*.html")
+//	c.HTML(http.StatusOK, "index.html", data)
+func (engine *Engine) LoadHTMLGlob(pattern string) {
+	root, rel := splitGlobRoot(pattern)
+	engine.LoadHTMLFS(os.DirFS(root), rel)
+}
+
+// LoadHTMLFS 从 fs.FS (如 embed.FS) 中解析模板, 模板名为文件在 fsys 中的路径.
+// 开发模式下轮询模板文件并在修改后重新加载, 解析错误不会 panic 而是在请求时展示.
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	htmlRender := &render.HTMLEngine{
+		Delims:   engine.delims,
+		FuncMap:  engine.FuncMap,
+		FS:       fsys,
+		Patterns: patterns,
+		Debug:    engine.DevMode,
+	}
+	if err := htmlRender.Load(); err != nil && !engine.DevMode {
+		panic(err)
+	}
+
+	if engine.HTMLRender != nil {
+		engine.HTMLRender.StopWatch()
+	}
+	engine.HTMLRender = htmlRender
+	if engine.DevMode {
+		htmlRender.Watch(templatePollInterval)
+		engine.OnShutdown(func(ctx context.Context) {
+			htmlRender.StopWatch()
+		})
+	}
+}
+
+// splitGlobRoot 将 pattern 拆分为不含通配符的根目录和相对的 pattern
+func splitGlobRoot(pattern string) (root, rel string) {
+	pattern = filepath.ToSlash(pattern)
+	i := strings.IndexAny(pattern, "*?[\\")
+	if i < 0 {
+		i = len(pattern)
+	}
+	slash := strings.LastIndex(pattern[:i], "/")
+	if slash < 0 {
+		return ".", pattern
+	}
+	return filepath.FromSlash(pattern[:slash]), pattern[slash+1:]
+}
+
+// HTML 按名称渲染 LoadHTMLGlob/LoadHTMLFS 加载的模板
+func (c *Context) HTML(code int, name string, data interface{}) {
+	c.Render(code, c.Engine.HTMLRender.Instance(name, data))
+}
@@ -0,0 +1,321 @@
+package gout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHubQueueSize    = 256
+	defaultHubWriteTimeout = 10 * time.Second
+)
+
+var (
+	ErrHubClosed      = errors.New("hub: closed")
+	ErrHubDuplicateID = errors.New("hub: duplicate connection id")
+	ErrHubNotFound    = errors.New("hub: connection not found")
+	ErrHubSlowClient  = errors.New("hub: slow consumer evicted")
+)
+
+// HubConfig websocket 连接注册中心配置
+type HubConfig struct {
+	// QueueSize 每个连接的发送队列长度, 队列满时视为慢消费者并断开, 0 使用默认值 256
+	QueueSize int
+	// WriteTimeout 单条消息的写超时, 0 使用默认值 10s
+	WriteTimeout time.Duration
+}
+
+type hubMessage struct {
+	messageType int
+	data        []byte
+}
+
+// HubClient 注册到 Hub 的连接
+type HubClient struct {
+	ID   string
+	Conn *WSConn
+
+	hub       *Hub
+	send      chan hubMessage
+	rooms     map[string]struct{} // 由 hub.mu 保护
+	closed    bool                // 由 hub.mu 保护
+	closeCode int                 // 非0时发送队列写完后发送关闭帧并断开连接
+	closeText string
+}
+
+// Hub websocket 连接注册中心, 支持按 ID 发送, 房间广播和全局广播
+type Hub struct {
+	cfg     HubConfig
+	mu      sync.RWMutex
+	clients map[string]*HubClient
+	rooms   map[string]map[string]*HubClient
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewHub 创建 Hub, 并在 engine 关闭时排空所有连接
+func (engine *Engine) NewHub(cfg HubConfig) *Hub {
+	hub := NewHub(cfg)
+	engine.OnShutdown(func(ctx context.Context) {
+		_ = hub.Shutdown(ctx)
+	})
+	return hub
+}
+
+// NewHub 创建 Hub
+func NewHub(cfg HubConfig) *Hub {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultHubQueueSize
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultHubWriteTimeout
+	}
+	return &Hub{
+		cfg:     cfg,
+		clients: make(map[string]*HubClient),
+		rooms:   make(map[string]map[string]*HubClient),
+	}
+}
+
+// Register 注册连接并启动写协程, 连接结束时需以返回的 HubClient 调用 Unregister
+func (h *Hub) Register(id string, conn *WSConn) (*HubClient, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	if _, exists := h.clients[id]; exists {
+		return nil, ErrHubDuplicateID
+	}
+
+	client := &HubClient{
+		ID:    id,
+		Conn:  conn,
+		hub:   h,
+		send:  make(chan hubMessage, h.cfg.QueueSize),
+		rooms: make(map[string]struct{}),
+	}
+	h.clients[id] = client
+	h.wg.Add(1)
+	go client.writeLoop()
+	return client, nil
+}
+
+// Unregister 注销连接, 已入队的消息仍会写出.
+// 按 HubClient 而不是 ID 注销, 客户端以相同 ID 重连后, 旧连接的注销不会移除新连接.
+func (h *Hub) Unregister(client *HubClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client.ID] == client {
+		h.removeLocked(client)
+	}
+}
+
+// removeLocked 从注册表和所有房间中移除连接并关闭发送队列, 调用方需持有 mu
+func (h *Hub) removeLocked(client *HubClient) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	delete(h.clients, client.ID)
+	for room := range client.rooms {
+		h.leaveLocked(client, room)
+	}
+	close(client.send)
+}
+
+// Join 将连接加入房间
+func (h *Hub) Join(id, room string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.clients[id]
+	if !ok {
+		return ErrHubNotFound
+	}
+
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[string]*HubClient)
+		h.rooms[room] = members
+	}
+	members[id] = client
+	client.rooms[room] = struct{}{}
+	return nil
+}
+
+// Leave 将连接移出房间
+func (h *Hub) Leave(id, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client, ok := h.clients[id]; ok {
+		h.leaveLocked(client, room)
+	}
+}
+
+func (h *Hub) leaveLocked(client *HubClient, room string) {
+	delete(client.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, client.ID)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// Rooms 连接所在的房间
+func (h *Hub) Rooms(id string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	client, ok := h.clients[id]
+	if !ok {
+		return nil
+	}
+	rooms := make([]string, 0, len(client.rooms))
+	for room := range client.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Members 房间内的连接 ID
+func (h *Hub) Members(room string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := make([]string, 0, len(h.rooms[room]))
+	for id := range h.rooms[room] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Len 已注册的连接数
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Send 向指定连接发送消息
+func (h *Hub) Send(id string, messageType int, data []byte) error {
+	h.mu.RLock()
+	client, ok := h.clients[id]
+	if !ok {
+		h.mu.RUnlock()
+		return ErrHubNotFound
+	}
+	queued := client.enqueue(hubMessage{messageType, data})
+	h.mu.RUnlock()
+
+	if !queued {
+		h.evict(client)
+		return ErrHubSlowClient
+	}
+	return nil
+}
+
+// Broadcast 向所有连接发送消息
+func (h *Hub) Broadcast(messageType int, data []byte) {
+	h.mu.RLock()
+	slow := h.broadcastLocked(h.clients, hubMessage{messageType, data})
+	h.mu.RUnlock()
+	h.evict(slow...)
+}
+
+// BroadcastRoom 向房间内所有连接发送消息
+func (h *Hub) BroadcastRoom(room string, messageType int, data []byte) {
+	h.mu.RLock()
+	slow := h.broadcastLocked(h.rooms[room], hubMessage{messageType, data})
+	h.mu.RUnlock()
+	h.evict(slow...)
+}
+
+// broadcastLocked 入队消息并返回队列已满的连接, 调用方需持有 mu 读锁
+func (h *Hub) broadcastLocked(clients map[string]*HubClient, msg hubMessage) (slow []*HubClient) {
+	for _, client := range clients {
+		if !client.enqueue(msg) {
+			slow = append(slow, client)
+		}
+	}
+	return
+}
+
+// evict 断开慢消费者, 丢弃其未发送的消息
+func (h *Hub) evict(clients ...*HubClient) {
+	if len(clients) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, client := range clients {
+		h.removeLocked(client)
+	}
+	h.mu.Unlock()
+
+	// 直接关闭底层连接, 避免等待阻塞中的写入
+	for _, client := range clients {
+		_ = client.Conn.conn.Close()
+	}
+}
+
+// Shutdown 停止接收新连接, 写完所有已入队消息后发送关闭帧, ctx 结束时强制断开
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*HubClient, 0, len(h.clients))
+	for _, client := range h.clients {
+		client.closeCode, client.closeText = CloseGoingAway, "server shutdown"
+		clients = append(clients, client)
+		h.removeLocked(client)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, client := range clients {
+			_ = client.Conn.conn.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+// enqueue 非阻塞入队, 队列已满返回 false, 调用方需持有 hub.mu 读锁
+func (c *HubClient) enqueue(msg hubMessage) bool {
+	if c.closed {
+		return true
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *HubClient) writeLoop() {
+	defer c.hub.wg.Done()
+
+	for msg := range c.send {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(c.hub.cfg.WriteTimeout))
+		if err := c.Conn.WriteMessage(msg.messageType, msg.data); err != nil {
+			// 写失败后丢弃剩余消息, 等待发送队列关闭
+			for range c.send {
+			}
+			return
+		}
+	}
+
+	if c.closeCode != 0 {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(c.hub.cfg.WriteTimeout))
+		_ = c.Conn.WriteClose(c.closeCode, c.closeText)
+		_ = c.Conn.conn.Close()
+	}
+}
@@ -0,0 +1,183 @@
+package gout
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+const defaultMultipartMaxParts = 1000
+
+var (
+	ErrPartTooLarge          = errors.New("multipart: part too large")
+	ErrMultipartTooLarge     = errors.New("multipart: request body too large")
+	ErrMultipartTooManyParts = errors.New("multipart: too many parts")
+)
+
+// MultipartOption MultipartStream 配置
+type MultipartOption func(*multipartOptions)
+
+type multipartOptions struct {
+	maxPartSize  int64
+	maxTotalSize int64
+	maxParts     int
+	hashes       map[string]func() hash.Hash
+}
+
+// MultipartMaxPartSize 单个 part 的最大字节数, 0 不限制
+func MultipartMaxPartSize(n int64) MultipartOption {
+	return func(o *multipartOptions) { o.maxPartSize = n }
+}
+
+// MultipartMaxTotalSize 所有 part 内容的最大字节数, 0 不限制
+func MultipartMaxTotalSize(n int64) MultipartOption {
+	return func(o *multipartOptions) { o.maxTotalSize = n }
+}
+
+// MultipartMaxParts part 的最大数量, 默认 1000, 0 不限制
+func MultipartMaxParts(n int) MultipartOption {
+	return func(o *multipartOptions) { o.maxParts = n }
+}
+
+// MultipartHash 增加读取 part 时计算的校验和, 如 MultipartHash("sha256", sha256.New).
+// 默认不计算任何校验和, newHash 为 nil 时删除同名的校验和.
+func MultipartHash(name string, newHash func() hash.Hash) MultipartOption {
+	return func(o *multipartOptions) {
+		if newHash == nil {
+			delete(o.hashes, name)
+			return
+		}
+		o.hashes[name] = newHash
+	}
+}
+
+// Part multipart 请求中的一个 part, 读取时检查大小限制并计算校验和
+type Part struct {
+	Header textproto.MIMEHeader
+
+	part     *multipart.Part
+	opts     *multipartOptions
+	size     int64
+	total    *int64
+	hashes   map[string]hash.Hash
+	finished bool
+}
+
+// FormName 表单字段名
+func (p *Part) FormName() string {
+	return p.part.FormName()
+}
+
+// FileName 客户端提供的文件名, 不是文件时为空
+func (p *Part) FileName() string {
+	return p.part.FileName()
+}
+
+// ContentType 客户端提供的 Content-Type
+func (p *Part) ContentType() string {
+	return p.Header.Get("Content-Type")
+}
+
+// Size 已读取的字节数
+func (p *Part) Size() int64 {
+	return p.size
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	if p.finished {
+		return 0, io.EOF
+	}
+	n, err := p.part.Read(b)
+	if n > 0 {
+		p.size += int64(n)
+		*p.total += int64(n)
+		if p.opts.maxPartSize > 0 && p.size > p.opts.maxPartSize {
+			return 0, ErrPartTooLarge
+		}
+		if p.opts.maxTotalSize > 0 && *p.total > p.opts.maxTotalSize {
+			return 0, ErrMultipartTooLarge
+		}
+		for _, h := range p.hashes {
+			h.Write(b[:n])
+		}
+	}
+	if err == io.EOF {
+		p.finished = true
+	}
+	return n, err
+}
+
+// Sum 名为 name 的校验和, 只有 part 读取完后才是完整内容的校验和, 未通过 MultipartHash 设置时返回 nil
+func (p *Part) Sum(name string) []byte {
+	if h, ok := p.hashes[name]; ok {
+		return h.Sum(nil)
+	}
+	return nil
+}
+
+// Checksum 十六进制的校验和, 如 part.Checksum("sha256")
+func (p *Part) Checksum(name string) string {
+	return hex.EncodeToString(p.Sum(name))
+}
+
+// MultipartStream 依次读取 multipart 请求的每个 part 并交给 handler, 不经过内存或临时文件缓冲.
+// handler 返回前未读完的内容会被读取并丢弃, 以便检查大小限制.
+// 超出限制或 handler 返回错误时停止读取并返回该错误, 之后无法再通过 PostForm, FormFile 等读取表单.
+func (c *Context) MultipartStream(handler func(part *Part) error, opts ...MultipartOption) error {
+	options := &multipartOptions{
+		maxParts: defaultMultipartMaxParts,
+		hashes:   make(map[string]func() hash.Hash),
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	for name, newHash := range options.hashes {
+		if newHash() == nil {
+			return fmt.Errorf("multipart: hash %q constructor returned nil", name)
+		}
+	}
+
+	reader, err := c.Req.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for count := 0; ; count++ {
+		mp, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if options.maxParts > 0 && count >= options.maxParts {
+			mp.Close()
+			return ErrMultipartTooManyParts
+		}
+
+		part := &Part{
+			Header: mp.Header,
+			part:   mp,
+			opts:   options,
+			total:  &total,
+			hashes: make(map[string]hash.Hash, len(options.hashes)),
+		}
+		for name, newHash := range options.hashes {
+			part.hashes[name] = newHash()
+		}
+
+		err = handler(part)
+		if err == nil {
+			_, err = io.Copy(io.Discard, part)
+		}
+		mp.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
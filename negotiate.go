@@ -0,0 +1,193 @@
+package gout
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eicesoft/gout/render"
+)
+
+const NotAcceptable406 = "406 NOT ACCEPTABLE: %s\n"
+
+// Negotiate 内容协商配置, 按 Offered 的顺序作为同等权重时的优先级.
+// Offered 为空时使用已设置的 XXXData 字段对应的类型, text/plain 使用 Data
+type Negotiate struct {
+	Offered  []string
+	HTMLName string
+	HTMLData interface{}
+	JSONData interface{}
+	XMLData  interface{}
+	Data     interface{}
+
+	YAMLData     interface{}
+	TOMLData     interface{}
+	MsgPackData  interface{}
+	ProtoBufData interface{}
+}
+
+// acceptSpec Accept header 中的单个媒体类型
+type acceptSpec struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept 解析 Accept header, 忽略 q 以外的参数
+func parseAccept(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params := head(part, ";")
+		typ, subtype := head(strings.TrimSpace(mediaType), "/")
+		if typ == "" || subtype == "" {
+			continue
+		}
+
+		spec := acceptSpec{typ: strings.ToLower(typ), subtype: strings.ToLower(subtype), q: 1}
+		for params != "" {
+			var param string
+			param, params = head(params, ";")
+			if k, v := head(strings.TrimSpace(param), "="); strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+					spec.q = q
+				}
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// specificity 返回 spec 与 offer 的匹配精确度, -1 表示不匹配
+func (spec acceptSpec) specificity(typ, subtype string) int {
+	switch {
+	case spec.typ == typ && spec.subtype == subtype:
+		return 2
+	case spec.typ == typ && spec.subtype == "*":
+		return 1
+	case spec.typ == "*" && spec.subtype == "*":
+		return 0
+	}
+	return -1
+}
+
+// NegotiateFormat 根据 Accept header 从 offered 中选出最合适的类型, 无匹配或 offered 为空时返回空字符串
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+	specs := parseAccept(accept)
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offered {
+		typ, subtype := head(strings.ToLower(filterFlags(offer)), "/")
+
+		// 由最精确匹配的媒体类型决定 offer 的权重
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s := spec.specificity(typ, subtype); s > specificity {
+				q, specificity = spec.q, s
+			}
+		}
+		if specificity < 0 || q == 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// Negotiate 根据 Accept header 选择 JSON/XML/HTML/YAML/TOML/MsgPack/ProtoBuf 响应, 无匹配时返回 406
+func (c *Context) Negotiate(code int, config Negotiate) {
+	var r render.Render
+
+	offered := config.Offered
+	if len(offered) == 0 {
+		offered = config.implied()
+	}
+
+	// offer 可以带参数, 如 application/json; charset=utf-8
+	switch strings.ToLower(filterFlags(c.NegotiateFormat(offered...))) {
+	case MIMEJson:
+		r = render.JSON{Data: chooseData(config.JSONData, config.Data), Codec: c.Engine.jsonCodec()}
+	case MIMEHTML:
+		data := chooseData(config.HTMLData, config.Data)
+		if config.HTMLName != "" && c.Engine.HTMLRender != nil {
+			r = c.Engine.HTMLRender.Instance(config.HTMLName, data)
+		} else if config.HTMLName != "" {
+			r = render.Template{Data: data, Filename: config.HTMLName, Debug: c.Engine.DevMode}
+		} else if html, ok := data.(string); ok {
+			r = render.HTML{Data: html}
+		}
+	case MIMEPlain:
+		data := chooseData(nil, config.Data)
+		if text, ok := data.(string); ok {
+			r = render.Text{Data: text}
+		} else if data != nil {
+			r = render.Text{Data: fmt.Sprint(data)}
+		}
+	case MIMEXML, MIMEXML2:
+		r = render.XML{Data: chooseData(config.XMLData, config.Data)}
+	case MIMEYAML, MIMEYAML2:
+		r = render.YAML{Data: chooseData(config.YAMLData, config.Data)}
+	case MIMETOML:
+		r = render.TOML{Data: chooseData(config.TOMLData, config.Data)}
+	case MIMEMsgPack, MIMEMsgPack2:
+		r = render.MsgPack{Data: chooseData(config.MsgPackData, config.Data)}
+	case MIMEProtoBuf:
+		r = render.ProtoBuf{Data: chooseData(config.ProtoBufData, config.Data)}
+	}
+
+	if r == nil {
+		c.index = abortIndex
+		c.String(http.StatusNotAcceptable, NotAcceptable406, c.GetHeader("Accept"))
+		return
+	}
+	c.Render(code, r)
+}
+
+// implied 返回已设置数据的类型, 用于未指定 Offered 的情况
+func (config Negotiate) implied() []string {
+	var offered []string
+	if config.JSONData != nil {
+		offered = append(offered, MIMEJson)
+	}
+	if config.HTMLName != "" || config.HTMLData != nil {
+		offered = append(offered, MIMEHTML)
+	}
+	if config.XMLData != nil {
+		offered = append(offered, MIMEXML)
+	}
+	if config.YAMLData != nil {
+		offered = append(offered, MIMEYAML)
+	}
+	if config.TOMLData != nil {
+		offered = append(offered, MIMETOML)
+	}
+	if config.MsgPackData != nil {
+		offered = append(offered, MIMEMsgPack)
+	}
+	if config.ProtoBufData != nil {
+		offered = append(offered, MIMEProtoBuf)
+	}
+	return offered
+}
+
+func chooseData(custom, wildcard interface{}) interface{} {
+	if custom == nil {
+		return wildcard
+	}
+	return custom
+}
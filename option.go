@@ -1,7 +1,24 @@
 package gout
 
+import "github.com/eicesoft/gout/codec"
+
+// JSONCodec JSON 编解码器接口, 见 WrapOptionJSONCodec
+type JSONCodec = codec.JSONCodec
+
 type Options struct {
 	IsEnablePProf bool
+	IsDevMode     bool
+	JSONCodec     JSONCodec
+	CookieOptions *CookieOptions
+	CookieKeys    [][]byte
+
+	TrustedProxies  []string
+	RemoteIPHeaders []string
+	TrustedPlatform string
+
+	EnableDecoderDisallowUnknownFields bool
+	EnableDecoderUseNumber             bool
+	EnableDecoderStrict                bool
 }
 
 type Option func(*Options)
@@ -21,3 +38,74 @@ func WrapOptionPProf(enable bool) Option {
 		option.IsEnablePProf = enable
 	}
 }
+
+// WrapOptionCookie 设置 cookie 默认属性
+func WrapOptionCookie(defaults CookieOptions) Option {
+	return func(option *Options) {
+		option.CookieOptions = &defaults
+	}
+}
+
+// WrapOptionCookieKeys 设置签名/加密 cookie 的密钥, 新密钥放在第一个以实现密钥轮换
+func WrapOptionCookieKeys(keys ...[]byte) Option {
+	return func(option *Options) {
+		option.CookieKeys = keys
+	}
+}
+
+// WrapOptionTrustedProxies 设置受信任的代理 IP 或 CIDR
+func WrapOptionTrustedProxies(proxies ...string) Option {
+	return func(option *Options) {
+		option.TrustedProxies = proxies
+	}
+}
+
+// WrapOptionRemoteIPHeaders 设置 ClientIP 依次读取的header
+func WrapOptionRemoteIPHeaders(headers ...string) Option {
+	return func(option *Options) {
+		option.RemoteIPHeaders = headers
+	}
+}
+
+// WrapOptionTrustedPlatform 设置平台的客户端IP header, 如 PlatformCloudflare
+func WrapOptionTrustedPlatform(header string) Option {
+	return func(option *Options) {
+		option.TrustedPlatform = header
+	}
+}
+
+// WrapOptionDevMode 开发模式, 模板文件修改后自动重新加载
+func WrapOptionDevMode(enable bool) Option {
+	return func(option *Options) {
+		option.IsDevMode = enable
+	}
+}
+
+// WrapOptionJSONCodec 设置该 Engine 的 Json 编解码器, Context 的渲染和绑定都使用它, 只对该 Engine 生效.
+// 实现 codec.UnknownFieldReporter 和 codec.TypeErrorReporter 后解码错误会转换为 BindingError
+func WrapOptionJSONCodec(jsonCodec JSONCodec) Option {
+	return func(option *Options) {
+		option.JSONCodec = jsonCodec
+	}
+}
+
+// WrapOptionDecoderDisallowUnknownFields JSON 绑定时拒绝结构体中不存在的字段
+func WrapOptionDecoderDisallowUnknownFields(enable bool) Option {
+	return func(option *Options) {
+		option.EnableDecoderDisallowUnknownFields = enable
+	}
+}
+
+// WrapOptionDecoderUseNumber JSON 绑定时将 interface{} 中的数字解析为 json.Number 而不是 float64
+func WrapOptionDecoderUseNumber(enable bool) Option {
+	return func(option *Options) {
+		option.EnableDecoderUseNumber = enable
+	}
+}
+
+// WrapOptionDecoderStrict JSON 绑定时拒绝 JSON 值之后的多余数据
+func WrapOptionDecoderStrict(enable bool) Option {
+	return func(option *Options) {
+		option.EnableDecoderStrict = enable
+	}
+}
@@ -0,0 +1,237 @@
+package render
+
+import (
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvContentType = []string{"text/csv; charset=utf-8"}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CSV 输出结构体切片或行迭代器为 CSV.
+// Data 为结构体(或结构体指针)切片时, 表头取自字段的 csv tag, csv:"-" 忽略该字段.
+// Data 为空时使用 Rows 迭代器, 返回 false 时结束, Header 为其表头.
+type CSV struct {
+	Data   interface{}
+	Rows   func() ([]string, bool)
+	Header []string
+	Comma  rune // 分隔符, 0 使用 ','
+	BOM    bool // 输出 UTF-8 BOM, Excel 需要它来识别编码
+
+	// EscapeFormulas 以 =, +, -, @, \t, \r 开头的单元格前加上 ', 防止表格软件将其作为公式执行(CSV 注入).
+	// 负数等以 - 开头的值也会被转义, 输出用户提供的数据时应开启
+	EscapeFormulas bool
+}
+
+func (r CSV) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	if r.BOM {
+		if _, err := w.Write(utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if r.Comma != 0 {
+		cw.Comma = r.Comma
+	}
+	write := cw.Write
+	if r.EscapeFormulas {
+		write = func(record []string) error {
+			return cw.Write(escapeFormulas(record))
+		}
+	}
+
+	var err error
+	if r.Data != nil {
+		err = writeCSVStructs(write, r.Data)
+	} else {
+		err = writeCSVRows(write, r.Header, r.Rows)
+	}
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r CSV) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, csvContentType)
+}
+
+// escapeFormulas 返回转义后的 record, 不修改 record 本身
+func escapeFormulas(record []string) []string {
+	var escaped []string
+	for i, cell := range record {
+		if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			continue
+		}
+		if escaped == nil {
+			escaped = append([]string(nil), record...)
+		}
+		escaped[i] = "'" + cell
+	}
+	if escaped == nil {
+		return record
+	}
+	return escaped
+}
+
+func writeCSVRows(write func([]string) error, header []string, rows func() ([]string, bool)) error {
+	if len(header) > 0 {
+		if err := write(header); err != nil {
+			return err
+		}
+	}
+	if rows == nil {
+		return nil
+	}
+	for {
+		record, ok := rows()
+		if !ok {
+			return nil
+		}
+		if err := write(record); err != nil {
+			return err
+		}
+	}
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+func writeCSVStructs(write func([]string) error, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("render: CSV data must be a slice, got %T", data)
+	}
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("render: CSV data must be a slice of structs, got %T", data)
+	}
+
+	fields := csvFields(elem, nil)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	if err := write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		for row.Kind() == reflect.Ptr {
+			row = row.Elem()
+		}
+		for j, f := range fields {
+			record[j] = ""
+			if row.IsValid() {
+				if fv, ok := fieldByIndex(row, f.index); ok {
+					record[j] = csvValue(fv)
+				}
+			}
+		}
+		if err := write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvFields 返回结构体的导出字段, 匿名嵌入的结构体字段展开到同一层
+func csvFields(t reflect.Type, index []int) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, csvFields(ft, idx)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		fields = append(fields, csvField{name: tag, index: idx})
+	}
+	return fields
+}
+
+// fieldByIndex 与 reflect.Value.FieldByIndex 相同, 但遇到 nil 指针时返回 false
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func csvValue(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			return x.Format(time.RFC3339)
+		case encoding.TextMarshaler:
+			if b, err := x.MarshalText(); err == nil {
+				return string(b)
+			}
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
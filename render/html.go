@@ -0,0 +1,302 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sharedDirs 存放布局和公共片段的目录, 其中的模板对所有页面可见
+var sharedDirs = []string{"layouts", "partials"}
+
+// Delims 模板分隔符
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// HTMLEngine 启动时解析所有模板并缓存.
+// layouts/ 和 partials/ 目录下的模板是共享模板, 其余模板各自拥有一份共享模板的副本,
+// 因此不同页面可以用 {{ define }} 重写同一个 block.
+type HTMLEngine struct {
+	Delims   Delims
+	FuncMap  template.FuncMap
+	FS       fs.FS
+	Patterns []string
+	Debug    bool // 开发模式, 模板错误以错误页面展示
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	err       error // 最近一次解析的错误
+	dirty     int32 // 模板文件有变化, 下次请求时重新解析
+	stop      chan struct{}
+}
+
+// Load 从 FS 中按 Patterns 解析模板, 模板名为文件在 FS 中的路径.
+// 解析失败时保留上一次成功解析的模板.
+func (e *HTMLEngine) Load() error {
+	templates, err := e.parseAll()
+
+	e.mu.Lock()
+	if err == nil {
+		e.templates = templates
+	}
+	e.err = err
+	e.mu.Unlock()
+	return err
+}
+
+func (e *HTMLEngine) parseAll() (map[string]*template.Template, error) {
+	pages, shared, err := e.files()
+	if err != nil {
+		return nil, err
+	}
+
+	base := template.New("").Delims(e.Delims.Left, e.Delims.Right).Funcs(e.FuncMap)
+	for _, name := range shared {
+		if err = e.parse(base, name); err != nil {
+			return nil, err
+		}
+	}
+
+	templates := make(map[string]*template.Template, len(pages)+len(shared))
+	for _, name := range shared {
+		templates[name] = base
+	}
+	for _, name := range pages {
+		tmpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err = e.parse(tmpl, name); err != nil {
+			return nil, err
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// Watch 按 interval 轮询模板文件, 有变化时在下次请求时重新解析
+func (e *HTMLEngine) Watch(interval time.Duration) {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	e.stop = stop
+	e.mu.Unlock()
+
+	last := e.snapshot()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if current := e.snapshot(); current != last {
+					last = current
+					atomic.StoreInt32(&e.dirty, 1)
+				}
+			}
+		}
+	}()
+}
+
+// StopWatch 停止轮询
+func (e *HTMLEngine) StopWatch() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+}
+
+// snapshot 返回所有模板文件的名称, 大小和修改时间
+func (e *HTMLEngine) snapshot() string {
+	pages, shared, err := e.files()
+	if err != nil {
+		return err.Error()
+	}
+
+	var b strings.Builder
+	for _, name := range append(pages, shared...) {
+		if info, err := fs.Stat(e.FS, name); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// files 返回匹配的页面模板和共享模板
+func (e *HTMLEngine) files() (pages, shared []string, err error) {
+	seen := make(map[string]bool)
+	add := func(pattern string) error {
+		matches, err := fs.Glob(e.FS, pattern)
+		if err != nil {
+			return err
+		}
+		for _, name := range matches {
+			if seen[name] {
+				continue
+			}
+			if info, err := fs.Stat(e.FS, name); err != nil || info.IsDir() {
+				continue
+			}
+			seen[name] = true
+			if isShared(name) {
+				shared = append(shared, name)
+			} else {
+				pages = append(pages, name)
+			}
+		}
+		return nil
+	}
+
+	for _, pattern := range e.Patterns {
+		if err = add(pattern); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, dir := range sharedDirs {
+		if err = add(dir + "/*"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(pages)+len(shared) == 0 {
+		return nil, nil, fmt.Errorf("html/template: pattern matches no files: %q", e.Patterns)
+	}
+	sort.Strings(shared)
+	return pages, shared, nil
+}
+
+func isShared(name string) bool {
+	dir, _ := head(name, "/")
+	for _, shared := range sharedDirs {
+		if dir == shared && strings.Contains(name, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+func head(str, sep string) (string, string) {
+	idx := strings.Index(str, sep)
+	if idx < 0 {
+		return str, ""
+	}
+	return str[:idx], str[idx+len(sep):]
+}
+
+func (e *HTMLEngine) parse(t *template.Template, name string) error {
+	content, err := fs.ReadFile(e.FS, name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(content))
+	return err
+}
+
+// Lookup 按名称查找模板
+func (e *HTMLEngine) Lookup(name string) (*template.Template, bool) {
+	tmpl, err := e.lookup(name)
+	return tmpl, err == nil
+}
+
+func (e *HTMLEngine) lookup(name string) (*template.Template, error) {
+	if e == nil {
+		return nil, fmt.Errorf("html/template: no templates loaded, %q is undefined", name)
+	}
+	if atomic.CompareAndSwapInt32(&e.dirty, 1, 0) {
+		_ = e.Load()
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.Debug && e.err != nil {
+		return nil, e.err
+	}
+	tmpl, ok := e.templates[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("html/template: %q is undefined", name)
+	}
+	return tmpl, nil
+}
+
+// Instance 返回按名称渲染模板的 Render
+func (e *HTMLEngine) Instance(name string, data interface{}) Render {
+	return HTMLTemplate{Engine: e, Name: name, Data: data}
+}
+
+// HTMLTemplate 渲染 HTMLEngine 中的命名模板
+type HTMLTemplate struct {
+	Engine *HTMLEngine
+	Name   string
+	Data   interface{}
+}
+
+func (r HTMLTemplate) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	tmpl, err := r.Engine.lookup(r.Name)
+	if err != nil {
+		if r.Engine != nil && r.Engine.Debug {
+			return WriteTemplateError(w, r.Name, err)
+		}
+		return err
+	}
+
+	if r.Engine.Debug {
+		// 开发模式下先渲染到缓冲区, 执行出错时可以完整地展示错误页面
+		var buf bytes.Buffer
+		if err = tmpl.ExecuteTemplate(&buf, path.Clean(r.Name), r.Data); err != nil {
+			return WriteTemplateError(w, r.Name, err)
+		}
+		_, err = buf.WriteTo(w)
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, path.Clean(r.Name), r.Data)
+}
+
+func (r HTMLTemplate) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContentType)
+}
+
+var templateErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template Error</title>
+<style>
+body { margin: 0; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; background: #f6f8fa; color: #24292e; }
+header { background: #d73a49; color: #fff; padding: 16px 32px; font-size: 20px; }
+main { padding: 24px 32px; }
+pre { background: #fff; border: 1px solid #e1e4e8; border-left: 4px solid #d73a49; padding: 16px; white-space: pre-wrap; font-size: 14px; }
+</style>
+</head>
+<body>
+<header>Template Error: {{ .Name }}</header>
+<main><pre>{{ .Error }}</pre><p>The page reloads the template on the next request after the file is saved.</p></main>
+</body>
+</html>
+`))
+
+// WriteTemplateError 输出模板错误页面, 仅用于开发模式
+func WriteTemplateError(w http.ResponseWriter, name string, err error) error {
+	w.Header().Set("Content-Type", htmlContentType[0])
+	w.WriteHeader(http.StatusInternalServerError)
+	return templateErrorPage.Execute(w, struct {
+		Name  string
+		Error string
+	}{name, err.Error()})
+}
@@ -0,0 +1,150 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"unicode/utf16"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+var jsonpContentType = []string{"application/javascript; charset=utf-8"}
+
+// jsonpCallback 合法的 JSONP 回调名, 允许 a.b.c 形式的成员访问
+var jsonpCallback = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// IndentedJSON 缩进格式的 JSON, 用于调试接口
+type IndentedJSON struct {
+	Data  interface{}
+	Codec codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	var buf bytes.Buffer
+	enc := jsonCodec(r.Codec).NewEncoder(&buf)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(r.Data); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// SecureJSON 数组响应前加上前缀, 防止 JSON 劫持
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+	Codec  codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	jsonBytes, err := jsonCodec(r.Codec).Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(jsonBytes, []byte("[")) {
+		if _, err = w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(jsonBytes)
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// JSONP 回调名不合法或为空时输出普通 JSON
+type JSONP struct {
+	Callback string
+	Data     interface{}
+	Codec    codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r JSONP) Render(w http.ResponseWriter) error {
+	if !jsonpCallback.MatchString(r.Callback) {
+		return writeJSON(w, r.Data, r.Codec)
+	}
+
+	r.WriteContentType(w)
+	jsonBytes, err := jsonCodec(r.Codec).Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	// 注释前缀防止 Rosetta Flash 类攻击
+	_, err = fmt.Fprintf(w, "/**/ typeof %s === 'function' && %s(%s);", r.Callback, r.Callback, jsonBytes)
+	return err
+}
+
+func (r JSONP) WriteContentType(w http.ResponseWriter) {
+	if jsonpCallback.MatchString(r.Callback) {
+		writeContentType(w, jsonpContentType)
+	} else {
+		writeContentType(w, jsonContentType)
+	}
+}
+
+// AsciiJSON 非 ASCII 字符转义为 \uXXXX
+type AsciiJSON struct {
+	Data  interface{}
+	Codec codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r AsciiJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	jsonBytes, err := jsonCodec(r.Codec).Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, c := range string(jsonBytes) {
+		if c < 0x80 {
+			buf.WriteByte(byte(c))
+			continue
+		}
+		if c > 0xffff {
+			r1, r2 := utf16.EncodeRune(c)
+			fmt.Fprintf(&buf, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+		fmt.Fprintf(&buf, "\\u%04x", c)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (r AsciiJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// PureJSON 不转义 <, > 和 &
+type PureJSON struct {
+	Data  interface{}
+	Codec codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	var buf bytes.Buffer
+	enc := jsonCodec(r.Codec).NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.Data); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
@@ -0,0 +1,185 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+// ErrClientGone 客户端在流式响应过程中断开连接
+var ErrClientGone = errors.New("render: client disconnected")
+
+const (
+	defaultFlushEvery    = 100
+	defaultFlushInterval = time.Second
+)
+
+// StreamJSON 使用 Encoder 编码到 ResponseWriter.
+// 编码器仍会先在内存中生成完整的 JSON, 大量数据请使用 JSONArrayStream 或 NDJSON 逐个元素输出.
+type StreamJSON struct {
+	Data  interface{}
+	Codec codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r StreamJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return jsonCodec(r.Codec).NewEncoder(w).Encode(r.Data)
+}
+
+func (r StreamJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// JSONArrayStream 逐个编码元素并输出为 JSON 数组.
+// Source 可以是任意类型的 channel, slice, 或 func() (interface{}, bool) 形式的迭代器, 返回 false 时结束.
+// Done 关闭时(通常是请求的 Context().Done())停止输出并返回 ErrClientGone.
+// 输出满 FlushEvery 个元素, 距上次 flush 超过 FlushInterval, 或 channel 暂时没有数据时 flush.
+type JSONArrayStream struct {
+	Source        interface{}
+	FlushEvery    int           // 每输出多少个元素 flush 一次, 0 使用默认值 100
+	FlushInterval time.Duration // 距上次 flush 超过该时间后 flush, 0 使用默认值 1s
+	Done          <-chan struct{}
+	Codec         codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r JSONArrayStream) Render(w http.ResponseWriter) error {
+	flusher := newStreamFlusher(w, r.FlushEvery, r.FlushInterval)
+	next, err := newIterator(r.Source, r.Done, flusher.flush)
+	if err != nil {
+		return err
+	}
+
+	r.WriteContentType(w)
+
+	if _, err = w.Write([]byte{'['}); err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		item, ok, gone := next()
+		if gone {
+			return ErrClientGone
+		}
+		if !ok {
+			break
+		}
+
+		jsonBytes, err := jsonCodec(r.Codec).Marshal(item)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			jsonBytes = append([]byte{','}, jsonBytes...)
+		}
+		if _, err = w.Write(jsonBytes); err != nil {
+			return err
+		}
+		flusher.wrote()
+	}
+	_, err = w.Write([]byte{']'})
+	return err
+}
+
+func (r JSONArrayStream) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// streamFlusher 按元素数量和时间间隔 flush 流式响应
+type streamFlusher struct {
+	flusher  http.Flusher
+	every    int
+	interval time.Duration
+	pending  int
+	last     time.Time
+}
+
+func newStreamFlusher(w http.ResponseWriter, every int, interval time.Duration) *streamFlusher {
+	if every <= 0 {
+		every = defaultFlushEvery
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	flusher, _ := w.(http.Flusher)
+	return &streamFlusher{flusher: flusher, every: every, interval: interval, last: time.Now()}
+}
+
+// wrote 记录输出了一个元素, 达到数量或时间间隔时 flush
+func (f *streamFlusher) wrote() {
+	f.pending++
+	if f.pending >= f.every || time.Since(f.last) >= f.interval {
+		f.flush()
+	}
+}
+
+// flush 输出所有未 flush 的元素
+func (f *streamFlusher) flush() {
+	if f.pending == 0 {
+		return
+	}
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	f.pending = 0
+	f.last = time.Now()
+}
+
+// newIterator 将 source 统一为迭代函数, 支持 channel, slice 和 func() (interface{}, bool).
+// 返回的 gone 表示 done 已关闭. channel 暂时没有数据时, 阻塞等待之前调用 idle.
+func newIterator(source interface{}, done <-chan struct{}, idle func()) (func() (item interface{}, ok bool, gone bool), error) {
+	if next, ok := source.(func() (interface{}, bool)); ok {
+		return func() (interface{}, bool, bool) {
+			select {
+			case <-done:
+				return nil, false, true
+			default:
+			}
+			item, ok := next()
+			return item, ok, false
+		}, nil
+	}
+
+	v := reflect.ValueOf(source)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		i := 0
+		return func() (interface{}, bool, bool) {
+			select {
+			case <-done:
+				return nil, false, true
+			default:
+			}
+			if i >= v.Len() {
+				return nil, false, false
+			}
+			i++
+			return v.Index(i - 1).Interface(), true, false
+		}, nil
+	case reflect.Chan:
+		if v.Type().ChanDir()&reflect.RecvDir != 0 {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: v},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+				{Dir: reflect.SelectDefault},
+			}
+			return func() (interface{}, bool, bool) {
+				chosen, item, ok := reflect.Select(cases)
+				if chosen == 2 {
+					idle()
+					chosen, item, ok = reflect.Select(cases[:2])
+				}
+				if chosen == 1 {
+					return nil, false, true
+				}
+				if !ok {
+					return nil, false, false
+				}
+				return item.Interface(), true, false
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("render: unsupported stream source %T", source)
+}
@@ -0,0 +1,29 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/ugorji/go/codec"
+)
+
+var msgpackContentType = []string{"application/msgpack"}
+
+type MsgPack struct {
+	Data interface{}
+}
+
+func (r MsgPack) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	// 先编码到缓冲区, 编码失败时不会输出不完整的响应
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, new(codec.MsgpackHandle)).Encode(r.Data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (r MsgPack) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, msgpackContentType)
+}
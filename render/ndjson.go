@@ -0,0 +1,51 @@
+package render
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+var ndjsonContentType = []string{"application/x-ndjson"}
+
+// NDJSON 每行输出一个 JSON 对象, Source 和 flush 规则与 JSONArrayStream 相同
+type NDJSON struct {
+	Source        interface{}
+	FlushEvery    int           // 每输出多少行 flush 一次, 0 使用默认值 100
+	FlushInterval time.Duration // 距上次 flush 超过该时间后 flush, 0 使用默认值 1s
+	Done          <-chan struct{}
+	Codec         codec.JSONCodec // 为 nil 时使用 codec.JSON
+}
+
+func (r NDJSON) Render(w http.ResponseWriter) error {
+	flusher := newStreamFlusher(w, r.FlushEvery, r.FlushInterval)
+	next, err := newIterator(r.Source, r.Done, flusher.flush)
+	if err != nil {
+		return err
+	}
+
+	r.WriteContentType(w)
+	for {
+		item, ok, gone := next()
+		if gone {
+			return ErrClientGone
+		}
+		if !ok {
+			return nil
+		}
+
+		jsonBytes, err := jsonCodec(r.Codec).Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(append(jsonBytes, '\n')); err != nil {
+			return err
+		}
+		flusher.wrote()
+	}
+}
+
+func (r NDJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, ndjsonContentType)
+}
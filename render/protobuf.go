@@ -0,0 +1,33 @@
+package render
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var protobufContentType = []string{"application/x-protobuf"}
+
+// ProtoBuf Data 必须实现 proto.Message
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	msg, ok := r.Data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("render: %T does not implement proto.Message", r.Data)
+	}
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (r ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, protobufContentType)
+}
@@ -17,6 +17,22 @@ var (
 	_ Render = XML{}
 	_ Render = Redirect{}
 	_ Render = Template{}
+	_ Render = SSEvent{}
+	_ Render = Reader{}
+	_ Render = HTMLTemplate{}
+	_ Render = IndentedJSON{}
+	_ Render = SecureJSON{}
+	_ Render = JSONP{}
+	_ Render = AsciiJSON{}
+	_ Render = PureJSON{}
+	_ Render = StreamJSON{}
+	_ Render = JSONArrayStream{}
+	_ Render = YAML{}
+	_ Render = TOML{}
+	_ Render = MsgPack{}
+	_ Render = ProtoBuf{}
+	_ Render = CSV{}
+	_ Render = NDJSON{}
 )
 
 func writeContentType(w http.ResponseWriter, value []string) {
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eicesoft/gout/codec"
+)
+
+var sseContentType = []string{"text/event-stream"}
+
+var fieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+// lineReplacer 将 \r\n 和单独的 \r 统一为 \n, 规范中三者都是行结束符
+var lineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// SSEvent Server-Sent Events 中的单个事件
+type SSEvent struct {
+	Event string
+	Id    string
+	Retry uint // 客户端重连间隔, 毫秒
+	Data  interface{}
+	Codec codec.JSONCodec // 编码非字符串 Data, 为 nil 时使用 codec.JSON
+}
+
+func (r SSEvent) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return encodeSSEvent(w, r)
+}
+
+func (r SSEvent) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	writeContentType(w, sseContentType)
+	if _, exist := header["Cache-Control"]; !exist {
+		header.Set("Cache-Control", "no-cache")
+	}
+	header.Set("Connection", "keep-alive")
+}
+
+func encodeSSEvent(w io.Writer, r SSEvent) error {
+	var b strings.Builder
+	if r.Id != "" {
+		b.WriteString("id: " + fieldReplacer.Replace(r.Id) + "\n")
+	}
+	if r.Event != "" {
+		b.WriteString("event: " + fieldReplacer.Replace(r.Event) + "\n")
+	}
+	if r.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatUint(uint64(r.Retry), 10) + "\n")
+	}
+
+	data, err := sseData(r.Data, r.Codec)
+	if err != nil {
+		return err
+	}
+	// 多行数据需要拆分为多个 data 字段, 否则数据中的换行可以注入 event, id 等字段
+	for _, line := range strings.Split(lineReplacer.Replace(data), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func sseData(data interface{}, c codec.JSONCodec) (string, error) {
+	switch v := data.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	case nil:
+		return "", nil
+	}
+	jsonBytes, err := jsonCodec(c).Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var tomlContentType = []string{"application/toml; charset=utf-8"}
+
+type TOML struct {
+	Data interface{}
+}
+
+func (r TOML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	bytes, err := toml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (r TOML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, tomlContentType)
+}
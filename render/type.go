@@ -1,13 +1,15 @@
 package render
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strconv"
+
+	"github.com/eicesoft/gout/codec"
 )
 
 var (
@@ -18,19 +20,29 @@ var (
 )
 
 type JSON struct {
-	Data interface{}
+	Data  interface{}
+	Codec codec.JSONCodec // 为 nil 时使用 codec.JSON
 }
 
-func (r JSON) Render(w http.ResponseWriter) (err error) {
-	if err = WriteJSON(w, r.Data); err != nil {
-		panic(err)
-	}
-	return
+func (r JSON) Render(w http.ResponseWriter) error {
+	return writeJSON(w, r.Data, r.Codec)
 }
 
 func WriteJSON(w http.ResponseWriter, obj interface{}) error {
+	return writeJSON(w, obj, nil)
+}
+
+// jsonCodec 渲染使用的编解码器, c 为 nil 时使用包级的 codec.JSON
+func jsonCodec(c codec.JSONCodec) codec.JSONCodec {
+	if c == nil {
+		return codec.JSON
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, obj interface{}, c codec.JSONCodec) error {
 	writeContentType(w, jsonContentType)
-	jsonBytes, err := json.Marshal(obj)
+	jsonBytes, err := jsonCodec(c).Marshal(obj)
 	if err != nil {
 		return err
 	}
@@ -108,7 +120,7 @@ type Redirect struct {
 
 func (r Redirect) Render(w http.ResponseWriter) (err error) {
 	if (r.Code < http.StatusMultipleChoices || r.Code > http.StatusPermanentRedirect) && r.Code != http.StatusCreated {
-		panic(fmt.Sprintf("Cannot redirect with status code %d", r.Code))
+		return fmt.Errorf("cannot redirect with status code %d", r.Code)
 	}
 
 	http.Redirect(w, r.Request, r.Location, r.Code)
@@ -120,27 +132,52 @@ func (r Redirect) WriteContentType(w http.ResponseWriter) {}
 type Template struct {
 	Data     interface{}
 	Filename string
+	Delims   Delims // 为空时使用 [[ ]]
+	Debug    bool   // 开发模式, 模板错误以错误页面展示
 }
 
-func parseTemplateOrPanic(t string) *template.Template {
-	tplParse, err := template.New("html").Delims("[[", "]]").Parse(t)
-	if err != nil {
-		panic(err)
+func parseTemplate(t string, delims Delims) (*template.Template, error) {
+	if delims.Left == "" && delims.Right == "" {
+		delims = Delims{Left: "[[", Right: "]]"}
 	}
-	return tplParse
+	return template.New("html").Delims(delims.Left, delims.Right).Parse(t)
 }
 
 func (r Template) Render(w http.ResponseWriter) (err error) {
 	r.WriteContentType(w)
-	fd, err := os.Open(r.Filename)
-	t, _ := ioutil.ReadAll(fd)
-	fd.Close()
-	tmpl := parseTemplateOrPanic(string(t))
-	err = tmpl.Execute(w, r.Data)
+	t, err := ioutil.ReadFile(r.Filename)
+	if err == nil {
+		var tmpl *template.Template
+		if tmpl, err = parseTemplate(string(t), r.Delims); err == nil {
+			return tmpl.Execute(w, r.Data)
+		}
+	}
 
-	return
+	if r.Debug {
+		return WriteTemplateError(w, r.Filename, err)
+	}
+	return err
 }
 
 func (r Template) WriteContentType(w http.ResponseWriter) {
 	writeContentType(w, htmlContentType)
 }
+
+type Reader struct {
+	ContentType   string
+	ContentLength int64
+	Reader        io.Reader
+}
+
+func (r Reader) Render(w http.ResponseWriter) (err error) {
+	r.WriteContentType(w)
+	if r.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	_, err = io.Copy(w, r.Reader)
+	return
+}
+
+func (r Reader) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, []string{r.ContentType})
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlContentType = []string{"application/x-yaml; charset=utf-8"}
+
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	bytes, err := yaml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (r YAML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, yamlContentType)
+}
@@ -53,8 +53,10 @@ func (w *responseWriter) reset(writer http.ResponseWriter) {
 
 func (w *responseWriter) WriteHeader(code int) {
 	if code > 0 && w.status != code {
+		// 响应已写出时状态码无法再修改, 只记录警告, 不能因此终止进程
 		if w.Written() {
-			log.Fatalf("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.status, code)
+			log.Printf("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.status, code)
+			return
 		}
 		w.status = code
 	}
@@ -1,7 +1,6 @@
 package gout
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -9,6 +8,8 @@ import (
 	"strings"
 	"time"
 	"unsafe"
+
+	"github.com/eicesoft/gout/codec"
 )
 
 var errUnknownType = errors.New("unknown type")
@@ -16,6 +17,7 @@ var errUnknownType = errors.New("unknown type")
 type setOptions struct {
 	isDefaultExists bool
 	defaultValue    string
+	jsonCodec       codec.JSONCodec // 解码值中的 Json, 为 nil 时使用 codec.JSON
 }
 
 type setter interface {
@@ -54,7 +56,7 @@ func tryToSetValue(value reflect.Value, field reflect.StructField, setter setter
 		}
 	}
 
-	return setter.TrySet(value, field, tagValue, setOpt)
+	return setNested(value, field, setter, tagValue, tag, setOpt)
 }
 
 type formSource map[string][]string
@@ -66,25 +68,37 @@ func (form formSource) TrySet(value reflect.Value, field reflect.StructField, ta
 }
 
 func setByForm(value reflect.Value, field reflect.StructField, form map[string][]string, tagValue string, opt setOptions) (isSetted bool, err error) {
+	// 文件字段只能由上传的文件绑定, 见 multipartSource
+	if isFileType(value.Type()) {
+		return false, nil
+	}
+
 	vs, ok := form[tagValue]
 	if !ok && !opt.isDefaultExists {
 		return false, nil
 	}
 
-	switch value.Kind() {
+	kind := value.Kind()
+	if hasCustomSetter(value.Type()) {
+		// 自定义类型即使底层是切片或数组, 也作为单个值绑定
+		kind = reflect.String
+	}
+
+	switch kind {
 	case reflect.Slice:
-		if !ok {
-			vs = []string{opt.defaultValue}
+		if vs, err = collectionValues(vs, ok, field, opt); err != nil {
+			return false, err
 		}
-		return true, setSlice(vs, value, field)
+		return true, setSlice(vs, value, field, tagValue, opt.jsonCodec)
 	case reflect.Array:
-		if !ok {
-			vs = []string{opt.defaultValue}
+		if vs, err = collectionValues(vs, ok, field, opt); err != nil {
+			return false, err
 		}
 		if len(vs) != value.Len() {
-			return false, fmt.Errorf("%q is not valid value for %s", vs, value.Type().String())
+			return false, newBindingError(tagValue, strings.Join(vs, ","), value,
+				fmt.Errorf("expected %d values, got %d", value.Len(), len(vs)))
 		}
-		return true, setArray(vs, value, field)
+		return true, setArray(vs, value, field, tagValue, opt.jsonCodec)
 	default:
 		var val string
 		if !ok {
@@ -94,8 +108,49 @@ func setByForm(value reflect.Value, field reflect.StructField, form map[string][
 		if len(vs) > 0 {
 			val = vs[0]
 		}
-		return true, setWithProperType(val, value, field)
+		if err = setWithProperType(val, value, field, opt.jsonCodec); err != nil {
+			return true, newBindingError(tagValue, val, value, err)
+		}
+		return true, nil
+	}
+}
+
+// collectionSeparators collection_format 对应的分隔符, multi(默认) 使用重复的 key
+var collectionSeparators = map[string]string{
+	"csv":   ",",
+	"ssv":   " ",
+	"tsv":   "\t",
+	"pipes": "|",
+}
+
+// collectionValues 按字段的 collection_format 拆分切片的值.
+// key 不存在时使用默认值, 没有 collection_format 时默认值作为单个元素. 由于 tag 中的 , 用于分隔选项,
+// multi 和 csv 的默认值用 ; 分隔, 如 default=1;2;3
+func collectionValues(vs []string, ok bool, field reflect.StructField, opt setOptions) ([]string, error) {
+	format := field.Tag.Get("collection_format")
+	sep, known := collectionSeparators[format]
+	if !known && format != "" && format != "multi" {
+		return nil, fmt.Errorf("unknown collection_format %q on field %s", format, field.Name)
+	}
+
+	if !ok {
+		switch format {
+		case "":
+			return []string{opt.defaultValue}, nil
+		case "multi", "csv":
+			return strings.Split(opt.defaultValue, ";"), nil
+		}
+		return strings.Split(opt.defaultValue, sep), nil
+	}
+	if !known {
+		return vs, nil
 	}
+
+	values := make([]string, 0, len(vs))
+	for _, v := range vs {
+		values = append(values, strings.Split(v, sep)...)
+	}
+	return values, nil
 }
 
 func StringToBytes(s string) []byte {
@@ -107,7 +162,12 @@ func StringToBytes(s string) []byte {
 	))
 }
 
-func setWithProperType(val string, value reflect.Value, field reflect.StructField) error {
+// setWithProperType 按 value 的类型解析 val, 结构体和 map 按 Json 解码, jsonCodec 为 nil 时使用 codec.JSON
+func setWithProperType(val string, value reflect.Value, field reflect.StructField, jsonCodec codec.JSONCodec) error {
+	if ok, err := trySetCustom(val, value); ok {
+		return err
+	}
+
 	switch value.Kind() {
 	case reflect.Int:
 		return setIntField(val, 0, value)
@@ -141,14 +201,19 @@ func setWithProperType(val string, value reflect.Value, field reflect.StructFiel
 		return setFloatField(val, 64, value)
 	case reflect.String:
 		value.SetString(val)
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return setWithProperType(val, value.Elem(), field, jsonCodec)
 	case reflect.Struct:
 		switch value.Interface().(type) {
 		case time.Time:
 			return setTimeField(val, field, value)
 		}
-		return json.Unmarshal(StringToBytes(val), value.Addr().Interface())
+		return unmarshalJSON(jsonCodec, val, value)
 	case reflect.Map:
-		return json.Unmarshal(StringToBytes(val), value.Addr().Interface())
+		return unmarshalJSON(jsonCodec, val, value)
 	default:
 		return errUnknownType
 	}
@@ -250,19 +315,24 @@ func setTimeField(val string, structField reflect.StructField, value reflect.Val
 	return nil
 }
 
-func setArray(vals []string, value reflect.Value, field reflect.StructField) error {
+// setArray 设置所有元素, 失败的元素以 BindingErrors 返回, 字段路径为 key[i]
+func setArray(vals []string, value reflect.Value, field reflect.StructField, key string, jsonCodec codec.JSONCodec) error {
+	var errs BindingErrors
 	for i, s := range vals {
-		err := setWithProperType(s, value.Index(i), field)
+		err := setWithProperType(s, value.Index(i), field, jsonCodec)
 		if err != nil {
-			return err
+			errs = append(errs, newBindingError(fmt.Sprintf("%s[%d]", key, i), s, value.Index(i), err))
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
-func setSlice(vals []string, value reflect.Value, field reflect.StructField) error {
+func setSlice(vals []string, value reflect.Value, field reflect.StructField, key string, jsonCodec codec.JSONCodec) error {
 	slice := reflect.MakeSlice(value.Type(), len(vals), len(vals))
-	err := setArray(vals, slice, field)
+	err := setArray(vals, slice, field, key, jsonCodec)
 	if err != nil {
 		return err
 	}
@@ -270,6 +340,13 @@ func setSlice(vals []string, value reflect.Value, field reflect.StructField) err
 	return nil
 }
 
+func unmarshalJSON(jsonCodec codec.JSONCodec, val string, value reflect.Value) error {
+	if jsonCodec == nil {
+		jsonCodec = codec.JSON
+	}
+	return jsonCodec.Unmarshal(StringToBytes(val), value.Addr().Interface())
+}
+
 func setTimeDuration(val string, value reflect.Value, field reflect.StructField) error {
 	d, err := time.ParseDuration(val)
 	if err != nil {
@@ -0,0 +1,308 @@
+package gout
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxFormSliceLen 按下标绑定切片时允许的最大长度, 防止 items[100000000] 这样的请求分配过多内存
+const maxFormSliceLen = 1024
+
+// nestedSetter 支持嵌套 key 的 setter, 如 user.name, items[0][sku], meta[color]
+type nestedSetter interface {
+	setter
+	// nested 返回 key 下的嵌套数据源, key 本身有值或没有嵌套 key 时返回 false
+	nested(key string) (nestedSetter, bool)
+	// keys 返回数据源中第一级的 key
+	keys() []string
+}
+
+var _ nestedSetter = formSource(nil)
+
+func (form formSource) nested(key string) (nestedSetter, bool) {
+	if _, ok := form[key]; ok {
+		return nil, false
+	}
+
+	var sub formSource
+	for k, vs := range form {
+		rest, ok := subKey(k, key)
+		if !ok {
+			continue
+		}
+		if sub == nil {
+			sub = make(formSource)
+		}
+		sub[rest] = append(sub[rest], vs...)
+	}
+	return sub, sub != nil
+}
+
+func (form formSource) keys() []string {
+	seen := make(map[string]bool, len(form))
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = appendFirstSegment(keys, seen, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subKey 返回 k 去掉 key 前缀后的嵌套 key, 如 key 为 items 时 items[0][sku] 返回 0[sku]
+func subKey(k, key string) (string, bool) {
+	if len(k) <= len(key) || !strings.HasPrefix(k, key) {
+		return "", false
+	}
+	rest := k[len(key):]
+	switch rest[0] {
+	case '.':
+		rest = rest[1:]
+	case '[':
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return "", false
+		}
+		rest = rest[1:end] + rest[end+1:]
+	default:
+		return "", false
+	}
+	// 忽略 ids[] 这类没有子 key 的写法
+	if rest == "" || rest[0] == '.' || rest[0] == '[' {
+		return "", false
+	}
+	return rest, true
+}
+
+// appendFirstSegment 将 k 的第一级 key 加入 keys, seen 用于去重
+func appendFirstSegment(keys []string, seen map[string]bool, k string) []string {
+	first := k
+	if i := strings.IndexAny(k, ".["); i > 0 {
+		first = k[:i]
+	}
+	if seen[first] {
+		return keys
+	}
+	seen[first] = true
+	return append(keys, first)
+}
+
+// mapValue 与 mapping 相同, 但文件类型作为叶子节点, 只能由上传的文件绑定, 不会递归绑定其字段
+func mapValue(value reflect.Value, field reflect.StructField, setter setter, tag string) (bool, error) {
+	if field.Tag.Get(tag) == "-" {
+		return false, nil
+	}
+	if isFileType(value.Type()) {
+		return tryToSetValue(value, field, setter, tag)
+	}
+
+	vKind := value.Kind()
+	if vKind == reflect.Ptr {
+		vPtr, isNew := value, false
+		if value.IsNil() {
+			vPtr, isNew = reflect.New(value.Type().Elem()), true
+		}
+		isSetted, err := mapValue(vPtr.Elem(), field, setter, tag)
+		if err != nil {
+			return false, err
+		}
+		if isNew && isSetted {
+			value.Set(vPtr)
+		}
+		return isSetted, nil
+	}
+
+	if vKind != reflect.Struct || !field.Anonymous {
+		ok, err := tryToSetValue(value, field, setter, tag)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	if vKind != reflect.Struct {
+		return false, nil
+	}
+	tValue := value.Type()
+	var isSetted bool
+	for i := 0; i < value.NumField(); i++ {
+		sf := tValue.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		ok, err := mapValue(value.Field(i), sf, setter, tag)
+		if err != nil {
+			return false, err
+		}
+		isSetted = isSetted || ok
+	}
+	return isSetted, nil
+}
+
+// canNest value 是否可以从嵌套 key 绑定
+func canNest(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isFileType(t) || hasCustomSetter(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// setNested key 下有嵌套数据时递归绑定, 否则按普通字段绑定
+func setNested(value reflect.Value, field reflect.StructField, setter setter, key, tag string, opt setOptions) (bool, error) {
+	if c, ok := setter.(*errorCollector); ok && !c.accepts(field) {
+		return false, nil
+	}
+	if ns, ok := setter.(nestedSetter); ok && canNest(value.Type()) {
+		if sub, ok := ns.nested(key); ok {
+			isSetted, err := bindNested(value, field, sub, tag)
+			err = prefixBindingError(err, key)
+			if c, ok := setter.(*errorCollector); ok {
+				return c.collect(isSetted, err)
+			}
+			return isSetted, err
+		}
+	}
+	return setter.TrySet(value, field, key, opt)
+}
+
+func bindNested(value reflect.Value, field reflect.StructField, sub nestedSetter, tag string) (bool, error) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		ptr, isNew := value, false
+		if value.IsNil() {
+			ptr, isNew = reflect.New(value.Type().Elem()), true
+		}
+		isSetted, err := bindNested(ptr.Elem(), field, sub, tag)
+		if isNew && isSetted {
+			value.Set(ptr)
+		}
+		return isSetted, err
+	case reflect.Struct:
+		return mapValue(value, emptyField, sub, tag)
+	case reflect.Map:
+		return bindMap(value, field, sub, tag)
+	case reflect.Slice, reflect.Array:
+		return bindIndexed(value, field, sub, tag)
+	}
+	return false, nil
+}
+
+// bindMap 从 key[sub]=v 绑定 map[string]T
+func bindMap(value reflect.Value, field reflect.StructField, sub nestedSetter, tag string) (bool, error) {
+	t := value.Type()
+	if t.Key().Kind() != reflect.String {
+		return false, errUnknownType
+	}
+	if value.IsNil() {
+		value.Set(reflect.MakeMap(t))
+	}
+
+	var isSetted bool
+	for _, k := range sub.keys() {
+		elem := reflect.New(t.Elem()).Elem()
+		ok, err := setNested(elem, field, sub, k, tag, setOptions{})
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			value.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+			isSetted = true
+		}
+	}
+	return isSetted, nil
+}
+
+// bindIndexed 从 key[i]=v 或 key[i][sub]=v 绑定切片和数组
+func bindIndexed(value reflect.Value, field reflect.StructField, sub nestedSetter, tag string) (bool, error) {
+	maxLen := maxFormSliceLen
+	if value.Kind() == reflect.Array {
+		maxLen = value.Len()
+	}
+
+	// 无效的下标记录为错误, 其余下标继续绑定
+	var errs BindingErrors
+	keys := make([]string, 0)
+	indexes := make([]int, 0)
+	length := 0
+	for _, k := range sub.keys() {
+		idx, err := strconv.Atoi(k)
+		if err == nil && (idx < 0 || idx >= maxLen) {
+			err = fmt.Errorf("index out of range [0, %d)", maxLen)
+		}
+		if err != nil {
+			errs = append(errs, &BindingError{Field: "[" + k + "]", Value: k, Type: "index", Err: err})
+			continue
+		}
+		keys = append(keys, k)
+		indexes = append(indexes, idx)
+		if idx+1 > length {
+			length = idx + 1
+		}
+	}
+
+	target := value
+	if value.Kind() == reflect.Slice && value.Len() < length {
+		target = reflect.MakeSlice(value.Type(), length, length)
+		reflect.Copy(target, value)
+	}
+
+	var isSetted bool
+	for i, k := range keys {
+		ok, err := setNested(target.Index(indexes[i]), field, sub, k, tag, setOptions{})
+		if err != nil {
+			return false, err
+		}
+		isSetted = isSetted || ok
+	}
+	if isSetted && target != value {
+		value.Set(target)
+	}
+	if len(errs) > 0 {
+		return isSetted, errs
+	}
+	return isSetted, nil
+}
+
+// joinFieldPath 拼接字段路径, 数字下标使用 [i] 形式, 如 items[2].price
+func joinFieldPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	if child == "" {
+		return parent
+	}
+	if child[0] == '[' {
+		return parent + child
+	}
+	first, rest := child, ""
+	if i := strings.IndexAny(child, ".["); i > 0 {
+		first, rest = child[:i], child[i:]
+	}
+	if _, err := strconv.Atoi(first); err == nil {
+		return parent + "[" + first + "]" + rest
+	}
+	return parent + "." + child
+}
+
+// prefixBindingError 为嵌套字段的绑定错误加上父字段路径
+func prefixBindingError(err error, prefix string) error {
+	switch e := err.(type) {
+	case *BindingError:
+		e.Field = joinFieldPath(prefix, e.Field)
+	case BindingErrors:
+		for _, be := range e {
+			be.Field = joinFieldPath(prefix, be.Field)
+		}
+	}
+	return err
+}
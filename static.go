@@ -0,0 +1,172 @@
+package gout
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// hashedName 匹配扩展名前由 . 或 - 分隔的十六进制串, 如 app.3f9a1c2b.js 或 app-3f9a1c2b.css
+var hashedName = regexp.MustCompile(`[.-]([0-9a-fA-F]{8,64})\.[^./]+$`)
+
+// IsHashedName 默认的 StaticImmutable 匹配规则, 文件名带有同时包含字母和数字的 8-64 位十六进制哈希.
+// 纯数字的串(如 report-20241019.csv 中的日期)不视为哈希
+func IsHashedName(name string) bool {
+	m := hashedName.FindStringSubmatch(name)
+	if m == nil {
+		return false
+	}
+	return strings.IndexFunc(m[1], unicode.IsDigit) >= 0 && strings.IndexFunc(m[1], unicode.IsLetter) >= 0
+}
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+type staticOptions struct {
+	listing       bool
+	spaIndex      string
+	precompressed bool
+	immutable     func(name string) bool
+}
+
+// StaticOption 静态文件服务配置
+type StaticOption func(*staticOptions)
+
+// StaticListing 是否允许列出目录, 默认关闭
+func StaticListing(enable bool) StaticOption {
+	return func(o *staticOptions) { o.listing = enable }
+}
+
+// StaticSPA 找不到文件且路径没有扩展名时返回 index, 用于单页应用的前端路由
+func StaticSPA(index string) StaticOption {
+	return func(o *staticOptions) { o.spaIndex = index }
+}
+
+// StaticImmutable 设置判断文件内容不会变化的规则, 匹配的文件返回一年的 immutable 缓存.
+// 默认为 IsHashedName, 传入 nil 关闭
+func StaticImmutable(match func(name string) bool) StaticOption {
+	return func(o *staticOptions) { o.immutable = match }
+}
+
+// StaticPrecompressed 是否优先返回预压缩的 .br/.gz 文件, 默认开启
+func StaticPrecompressed(enable bool) StaticOption {
+	return func(o *staticOptions) { o.precompressed = enable }
+}
+
+// StaticFile 注册单个静态文件
+func (group *RouterGroup) StaticFile(relativePath, filepath string) {
+	if strings.Contains(relativePath, ":") || strings.Contains(relativePath, "*") {
+		panic("URL parameters can not be used when serving a static file")
+	}
+	handler := func(c *Context) {
+		c.File(filepath)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
+
+// Static 注册本地目录, 如 router.Static("/static", "/var/www")
+func (group *RouterGroup) Static(relativePath, root string, opts ...StaticOption) {
+	group.StaticFS(relativePath, http.Dir(root), opts...)
+}
+
+// StaticFS 注册 http.FileSystem, embed.FS 可以通过 http.FS 转换后使用
+func (group *RouterGroup) StaticFS(relativePath string, fs http.FileSystem, opts ...StaticOption) {
+	if strings.Contains(relativePath, ":") || strings.Contains(relativePath, "*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+
+	o := staticOptions{precompressed: true, immutable: IsHashedName}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	handler := func(c *Context) {
+		c.serveStatic(fs, path.Clean("/"+c.Param("filepath")), o)
+	}
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+}
+
+func (c *Context) serveStatic(fs http.FileSystem, name string, o staticOptions) {
+	f, err := fs.Open(name)
+	if err != nil {
+		if o.spaIndex != "" && path.Ext(name) == "" {
+			c.serveStatic(fs, path.Join("/", o.spaIndex), staticOptions{precompressed: o.precompressed})
+			return
+		}
+		c.fileError(err)
+		return
+	}
+	info, err := f.Stat()
+	f.Close()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+
+	if info.IsDir() {
+		index := path.Join(name, "index.html")
+		if ff, err := fs.Open(index); err == nil {
+			ff.Close()
+			name = index
+		} else if o.listing {
+			req := c.Req.Clone(c.Req.Context())
+			req.URL.Path = strings.TrimSuffix(name, "/") + "/"
+			http.FileServer(fs).ServeHTTP(c.Writer, req)
+			return
+		} else if o.spaIndex != "" {
+			c.serveStatic(fs, path.Join("/", o.spaIndex), staticOptions{precompressed: o.precompressed})
+			return
+		} else {
+			c.String(http.StatusNotFound, NoFound404, c.Path)
+			return
+		}
+	}
+
+	header := c.Writer.Header()
+	if o.immutable != nil && o.immutable(name) {
+		header.Set("Cache-Control", immutableCacheControl)
+	}
+
+	if o.precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		for _, enc := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !acceptsEncoding(c.GetHeader("Accept-Encoding"), enc.name) {
+				continue
+			}
+			cf, err := fs.Open(name + enc.ext)
+			if err != nil {
+				continue
+			}
+			cf.Close()
+
+			if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+				header.Set("Content-Type", ctype)
+			}
+			header.Set("Content-Encoding", enc.name)
+			c.serveContent(fsFile(fs, name+enc.ext), path.Base(name), "")
+			return
+		}
+	}
+
+	c.serveContent(fsFile(fs, name), path.Base(name), "")
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受 encoding, q=0 表示拒绝
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params := head(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		k, v := head(strings.TrimSpace(params), "=")
+		return !(strings.EqualFold(k, "q") && strings.Trim(v, "0.") == "")
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package gout
+
+import (
+	"io"
+
+	"github.com/eicesoft/gout/render"
+)
+
+// SSEvent 写入一个 Server-Sent Event
+func (c *Context) SSEvent(event string, data interface{}) {
+	c.SSE(render.SSEvent{Event: event, Data: data})
+}
+
+// SSE 写入一个完整的 Server-Sent Event, 可以设置 id 和 retry.
+// 客户端断线重连时会带上最后收到的 id, handler 需要调用 LastEventID 从该 id 之后继续推送
+func (c *Context) SSE(event render.SSEvent) {
+	if event.Codec == nil {
+		event.Codec = c.Engine.jsonCodec()
+	}
+	c.Render(-1, event)
+}
+
+// StreamJSON 使用 Encoder 编码到响应中返回Json数据, 大量数据请使用 JSONArrayStream 或 NDJSON
+func (c *Context) StreamJSON(code int, obj interface{}) {
+	c.Render(code, render.StreamJSON{Data: obj, Codec: c.Engine.jsonCodec()})
+}
+
+// JSONArrayStream 逐个输出 source 中的元素为 Json 数组, 客户端断开时停止.
+// source 可以是任意类型的 channel, slice, 或 func() (interface{}, bool) 形式的迭代器.
+func (c *Context) JSONArrayStream(code int, source interface{}) {
+	c.Render(code, render.JSONArrayStream{Source: source, Done: c.Req.Context().Done(), Codec: c.Engine.jsonCodec()})
+}
+
+// NDJSON 逐行输出 source 中的元素为 Json, 客户端断开时停止.
+// source 可以是任意类型的 channel, slice, 或 func() (interface{}, bool) 形式的迭代器.
+func (c *Context) NDJSON(code int, source interface{}) {
+	c.Render(code, render.NDJSON{Source: source, Done: c.Req.Context().Done(), Codec: c.Engine.jsonCodec()})
+}
+
+// NDJSONAttachment 以附件形式逐行输出 source 中的元素为 Json, filename 为客户端保存的文件名
+func (c *Context) NDJSONAttachment(code int, filename string, source interface{}) {
+	c.Attachment(filename)
+	c.NDJSON(code, source)
+}
+
+// LastEventID 获取客户端断线重连时携带的 Last-Event-ID, 首次连接时为空.
+// SSE 和 Stream 不会自动处理它, 由 handler 决定从哪里恢复推送
+func (c *Context) LastEventID() string {
+	return c.GetHeader("Last-Event-ID")
+}
+
+// Stream 持续调用 step 并在每次调用后 flush, step 返回 false 时结束.
+// 客户端断开连接时返回 true.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	w := c.Writer
+	clientGone := c.Req.Context().Done()
+	for {
+		select {
+		case <-clientGone:
+			return true
+		default:
+			keepOpen := step(w)
+			w.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
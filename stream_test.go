@@ -0,0 +1,64 @@
+package gout
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eicesoft/gout/render"
+)
+
+func TestSSEResumeFromLastEventID(t *testing.T) {
+	events := []string{"a", "b", "c", "d"}
+
+	engine := NewServer()
+	engine.GET("/events", func(c *Context) {
+		next := 0
+		if id := c.LastEventID(); id != "" {
+			last, err := strconv.Atoi(id)
+			if err != nil {
+				c.String(http.StatusBadRequest, "bad Last-Event-ID")
+				return
+			}
+			next = last + 1
+		}
+		c.Stream(func(w io.Writer) bool {
+			if next >= len(events) {
+				return false
+			}
+			c.SSE(render.SSEvent{Id: strconv.Itoa(next), Data: events[next]})
+			next++
+			return true
+		})
+	})
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        string
+	}{
+		{name: "first connection", want: "id: 0\ndata: a\n\nid: 1\ndata: b\n\nid: 2\ndata: c\n\nid: 3\ndata: d\n\n"},
+		{name: "resume", lastEventID: "1", want: "id: 2\ndata: c\n\nid: 3\ndata: d\n\n"},
+		{name: "up to date", lastEventID: "3", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events", nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("body = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+				t.Fatalf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
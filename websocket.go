@@ -0,0 +1,302 @@
+package gout
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocket 消息类型, 与 RFC 6455 opcode 一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// websocket 关闭状态码, RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	wsGUID                = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultWSReadLimit    = 16 << 20 // 16 MB
+	defaultWSFragmentSize = 4096
+)
+
+var (
+	ErrWSBadHandshake = errors.New("websocket: bad handshake")
+	ErrWSOrigin       = errors.New("websocket: request origin not allowed")
+	ErrWSCloseSent    = errors.New("websocket: close sent")
+	ErrWSReadLimit    = errors.New("websocket: read limit exceeded")
+)
+
+// WSConfig websocket 升级配置, 通过 Engine.WebSocket 设置
+type WSConfig struct {
+	// CheckOrigin 校验 Origin header, 为 nil 时只允许同源请求
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols 服务端支持的子协议, 按优先级排列
+	Subprotocols []string
+	// EnableCompression 是否协商 permessage-deflate
+	EnableCompression bool
+	// ReadLimit 单条消息的最大字节数, 0 使用默认值 16MB
+	ReadLimit int64
+	// FragmentSize 分片写入时单个帧的最大字节数, 0 使用默认值 4KB
+	FragmentSize int
+}
+
+// WSCloseError 收到对端关闭帧时 ReadMessage 返回的错误
+type WSCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WSConn websocket 连接, 写方法可并发调用, 读方法只允许一个 goroutine 调用
+type WSConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+	compress    bool
+
+	writeMu      sync.Mutex // 保证帧的完整写入
+	msgMu        sync.Mutex // 保证分片消息不被其他数据消息打断
+	closeSent    bool
+	fragmentSize int
+
+	readLimit   int64
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+}
+
+// WS 注册 websocket 路由, handler 返回后连接自动关闭
+func (group *RouterGroup) WS(pattern string, handler func(*WSConn)) {
+	group.GET(pattern, func(c *Context) {
+		conn, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	})
+}
+
+// Upgrade 完成 websocket 握手并接管底层连接, 握手失败时已写入错误响应
+func (c *Context) Upgrade() (*WSConn, error) {
+	cfg := c.Engine.WebSocket
+	r := c.Req
+
+	if r.Method != http.MethodGet {
+		return nil, c.wsError(http.StatusMethodNotAllowed, ErrWSBadHandshake)
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, c.wsError(http.StatusBadRequest, ErrWSBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, c.wsError(http.StatusUpgradeRequired, ErrWSBadHandshake)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, c.wsError(http.StatusBadRequest, ErrWSBadHandshake)
+	}
+
+	checkOrigin := cfg.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, c.wsError(http.StatusForbidden, ErrWSOrigin)
+	}
+
+	subprotocol := selectSubprotocol(r, cfg.Subprotocols)
+	compress := cfg.EnableCompression && offersDeflate(r)
+
+	netConn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before handshake is complete")
+	}
+	// 清除 http.Server 设置的超时, 由 WSConn 的 deadline 接管
+	_ = netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	b.WriteString("\r\n")
+	if _, err = netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	ws := &WSConn{
+		conn:         netConn,
+		br:           brw.Reader,
+		subprotocol:  subprotocol,
+		compress:     compress,
+		readLimit:    cfg.ReadLimit,
+		fragmentSize: cfg.FragmentSize,
+	}
+	if ws.readLimit <= 0 {
+		ws.readLimit = defaultWSReadLimit
+	}
+	if ws.fragmentSize <= 0 {
+		ws.fragmentSize = defaultWSFragmentSize
+	}
+	return ws, nil
+}
+
+func (c *Context) wsError(code int, err error) error {
+	c.index = abortIndex
+	c.String(code, "%s\n", http.StatusText(code))
+	return err
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin 默认的 Origin 校验, 没有 Origin header 的非浏览器客户端直接放行
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, serverProtocol := range supported {
+		if headerContainsToken(r.Header, "Sec-WebSocket-Protocol", serverProtocol) {
+			return serverProtocol
+		}
+	}
+	return ""
+}
+
+func offersDeflate(r *http.Request) bool {
+	for _, v := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(v, ",") {
+			name, _ := head(strings.TrimSpace(ext), ";")
+			if strings.TrimSpace(name) == "permessage-deflate" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Subprotocol 协商的子协议
+func (ws *WSConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr 对端地址
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadDeadline 设置读超时
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写超时
+func (ws *WSConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 设置单条消息的最大字节数
+func (ws *WSConn) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+// SetPingHandler 设置收到 ping 时的处理函数, 默认回复 pong
+func (ws *WSConn) SetPingHandler(h func(data string) error) {
+	ws.pingHandler = h
+}
+
+// SetPongHandler 设置收到 pong 时的处理函数
+func (ws *WSConn) SetPongHandler(h func(data string) error) {
+	ws.pongHandler = h
+}
+
+// Ping 发送 ping 帧
+func (ws *WSConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data)
+}
+
+// WriteClose 发起关闭握手, 对端的关闭帧由 ReadMessage 以 WSCloseError 返回
+func (ws *WSConn) WriteClose(code int, text string) error {
+	return ws.WriteControl(CloseMessage, formatCloseMessage(code, text))
+}
+
+// Close 发送关闭帧(若未发送)并关闭底层连接
+func (ws *WSConn) Close() error {
+	ws.writeMu.Lock()
+	sent := ws.closeSent
+	ws.writeMu.Unlock()
+	if !sent {
+		_ = ws.WriteClose(CloseNormalClosure, "")
+	}
+	return ws.conn.Close()
+}
+
+func formatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	buf[0] = byte(code >> 8)
+	buf[1] = byte(code)
+	copy(buf[2:], text)
+	return buf
+}
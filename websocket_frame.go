@@ -0,0 +1,387 @@
+package gout
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+const (
+	finBit  = 1 << 7
+	rsv1Bit = 1 << 6
+	rsv2Bit = 1 << 5
+	rsv3Bit = 1 << 4
+	maskBit = 1 << 7
+
+	continuationFrame      = 0
+	maxControlFramePayload = 125
+)
+
+// deflateTail permessage-deflate 压缩时去掉, 解压时补回的尾部, RFC 7692 7.2.1
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+func isControl(opcode int) bool {
+	return opcode == CloseMessage || opcode == PingMessage || opcode == PongMessage
+}
+
+func isData(opcode int) bool {
+	return opcode == TextMessage || opcode == BinaryMessage
+}
+
+func errWSProtocol(msg string) error {
+	return fmt.Errorf("websocket: protocol error: %s", msg)
+}
+
+// ReadMessage 读取一条完整消息, 自动处理分片, 控制帧和压缩.
+// 收到关闭帧时回复关闭帧并返回 *WSCloseError.
+func (ws *WSConn) ReadMessage() (messageType int, p []byte, err error) {
+	var compressed bool
+	for {
+		f, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch {
+		case isControl(f.opcode):
+			if err = ws.handleControl(f); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case isData(f.opcode):
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, errWSProtocol("data frame inside fragmented message"))
+			}
+			messageType, compressed = f.opcode, f.rsv1
+		case f.opcode == continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, errWSProtocol("continuation frame without message"))
+			}
+			if f.rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, errWSProtocol("rsv1 set on continuation frame"))
+			}
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, errWSProtocol(fmt.Sprintf("unknown opcode %d", f.opcode)))
+		}
+
+		if int64(len(p)+len(f.payload)) > ws.readLimit {
+			return 0, nil, ws.fail(CloseMessageTooBig, ErrWSReadLimit)
+		}
+		p = append(p, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		if compressed {
+			if p, err = ws.decompress(p); err != nil {
+				return 0, nil, err
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(p) {
+			return 0, nil, ws.fail(CloseInvalidFramePayloadData, errWSProtocol("invalid utf8 in text message"))
+		}
+		return messageType, p, nil
+	}
+}
+
+func (ws *WSConn) readFrame() (*wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.br, header[:]); err != nil {
+		return nil, err
+	}
+
+	f := &wsFrame{
+		fin:    header[0]&finBit != 0,
+		rsv1:   header[0]&rsv1Bit != 0,
+		opcode: int(header[0] & 0xf),
+	}
+	if header[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return nil, ws.fail(CloseProtocolError, errWSProtocol("unexpected rsv bits"))
+	}
+	if f.rsv1 && (!ws.compress || !isData(f.opcode) && f.opcode != continuationFrame) {
+		return nil, ws.fail(CloseProtocolError, errWSProtocol("unexpected rsv1 bit"))
+	}
+	if header[1]&maskBit == 0 {
+		return nil, ws.fail(CloseProtocolError, errWSProtocol("client frame is not masked"))
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return nil, ws.fail(CloseProtocolError, errWSProtocol("invalid payload length"))
+		}
+	}
+
+	if isControl(f.opcode) && (!f.fin || length > maxControlFramePayload) {
+		return nil, ws.fail(CloseProtocolError, errWSProtocol("invalid control frame"))
+	}
+	if length > ws.readLimit {
+		return nil, ws.fail(CloseMessageTooBig, ErrWSReadLimit)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return nil, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return nil, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i&3]
+	}
+	return f, nil
+}
+
+func (ws *WSConn) handleControl(f *wsFrame) error {
+	switch f.opcode {
+	case PingMessage:
+		if ws.pingHandler != nil {
+			return ws.pingHandler(string(f.payload))
+		}
+		if err := ws.WriteControl(PongMessage, f.payload); err != nil && err != ErrWSCloseSent {
+			return err
+		}
+	case PongMessage:
+		if ws.pongHandler != nil {
+			return ws.pongHandler(string(f.payload))
+		}
+	case CloseMessage:
+		closeErr := &WSCloseError{Code: CloseNoStatusReceived}
+		switch {
+		case len(f.payload) == 1:
+			return ws.fail(CloseProtocolError, errWSProtocol("invalid close payload"))
+		case len(f.payload) >= 2:
+			closeErr.Code = int(binary.BigEndian.Uint16(f.payload))
+			closeErr.Text = string(f.payload[2:])
+			if !validCloseCode(closeErr.Code) {
+				return ws.fail(CloseProtocolError, errWSProtocol("invalid close code"))
+			}
+			if !utf8.ValidString(closeErr.Text) {
+				return ws.fail(CloseInvalidFramePayloadData, errWSProtocol("invalid utf8 in close reason"))
+			}
+		}
+		// 回复关闭帧完成关闭握手
+		if err := ws.WriteClose(closeErr.Code, ""); err != nil && err != ErrWSCloseSent {
+			return err
+		}
+		return closeErr
+	}
+	return nil
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail 发送关闭帧后返回 err
+func (ws *WSConn) fail(code int, err error) error {
+	_ = ws.WriteClose(code, "")
+	return err
+}
+
+func (ws *WSConn) decompress(p []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(
+		bytes.NewReader(p),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff}), // 空的最终块, 使 reader 返回 EOF
+	))
+	defer fr.Close()
+
+	out, err := ioutil.ReadAll(io.LimitReader(fr, ws.readLimit+1))
+	if err != nil {
+		return nil, ws.fail(CloseInvalidFramePayloadData, err)
+	}
+	if int64(len(out)) > ws.readLimit {
+		return nil, ws.fail(CloseMessageTooBig, ErrWSReadLimit)
+	}
+	return out, nil
+}
+
+func compressMessage(p []byte) ([]byte, error) {
+	var b bytes.Buffer
+	fw, err := flate.NewWriter(&b, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fw.Write(p); err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), deflateTail), nil
+}
+
+// writeFrame 写入一个服务端帧(不加掩码), 调用方需持有 writeMu
+func (ws *WSConn) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+	var header [10]byte
+	header[0] = byte(opcode)
+	if fin {
+		header[0] |= finBit
+	}
+	if rsv1 {
+		header[0] |= rsv1Bit
+	}
+
+	n := 2
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n += 8
+	}
+
+	if _, err := ws.conn.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+// WriteControl 写入控制帧, 可以与数据消息并发调用
+func (ws *WSConn) WriteControl(messageType int, data []byte) error {
+	if !isControl(messageType) {
+		return fmt.Errorf("websocket: %d is not a control message type", messageType)
+	}
+	if len(data) > maxControlFramePayload {
+		return errWSProtocol("control frame payload too large")
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWSCloseSent
+	}
+	if messageType == CloseMessage {
+		ws.closeSent = true
+	}
+	return ws.writeFrame(true, false, messageType, data)
+}
+
+// WriteMessage 写入一条完整的数据消息
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	w, err := ws.NextWriter(messageType)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// NextWriter 返回一条分片消息的 writer, 必须调用 Close 结束消息.
+// Close 之前其他数据消息的写入会被阻塞, 控制帧不受影响.
+func (ws *WSConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if !isData(messageType) {
+		return nil, fmt.Errorf("websocket: %d is not a data message type", messageType)
+	}
+	ws.msgMu.Lock()
+	return &wsMessageWriter{ws: ws, opcode: messageType}, nil
+}
+
+type wsMessageWriter struct {
+	ws      *WSConn
+	opcode  int
+	buf     []byte
+	started bool
+	closed  bool
+}
+
+func (w *wsMessageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	w.buf = append(w.buf, p...)
+	// 压缩的消息需要完整内容, 未压缩时缓冲超过分片大小即写出
+	if !w.ws.compress {
+		for len(w.buf) > w.ws.fragmentSize {
+			if err := w.flushFrame(false, false, w.buf[:w.ws.fragmentSize]); err != nil {
+				return 0, err
+			}
+			w.buf = w.buf[w.ws.fragmentSize:]
+		}
+	}
+	return len(p), nil
+}
+
+func (w *wsMessageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.ws.msgMu.Unlock()
+
+	if !w.ws.compress {
+		return w.flushFrame(true, false, w.buf)
+	}
+
+	payload, err := compressMessage(w.buf)
+	if err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		n := len(payload)
+		if n > w.ws.fragmentSize {
+			n = w.ws.fragmentSize
+		}
+		fin := n == len(payload)
+		if err = w.flushFrame(fin, first, payload[:n]); err != nil || fin {
+			return err
+		}
+		payload = payload[n:]
+	}
+}
+
+func (w *wsMessageWriter) flushFrame(fin, rsv1 bool, payload []byte) error {
+	ws := w.ws
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWSCloseSent
+	}
+
+	opcode := continuationFrame
+	if !w.started {
+		opcode = w.opcode
+		w.started = true
+	}
+	return ws.writeFrame(fin, rsv1, opcode, payload)
+}
@@ -0,0 +1,584 @@
+package gout
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// bufConn 从 r 读取, 写入到 w, 用于不需要对端的帧测试
+type bufConn struct {
+	net.Conn
+	r io.Reader
+	w bytes.Buffer
+}
+
+func (c *bufConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *bufConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+func (c *bufConn) Close() error                { return nil }
+
+func newTestWSConn(conn net.Conn, compress bool, fragmentSize int) *WSConn {
+	return &WSConn{
+		conn:         conn,
+		br:           bufio.NewReader(conn),
+		compress:     compress,
+		readLimit:    defaultWSReadLimit,
+		fragmentSize: fragmentSize,
+	}
+}
+
+// clientFrame 按客户端格式(带掩码)编码一个帧
+func clientFrame(fin, rsv1 bool, opcode int, payload []byte) []byte {
+	var b bytes.Buffer
+	first := byte(opcode)
+	if fin {
+		first |= finBit
+	}
+	if rsv1 {
+		first |= rsv1Bit
+	}
+	b.WriteByte(first)
+
+	switch n := len(payload); {
+	case n <= 125:
+		b.WriteByte(maskBit | byte(n))
+	case n <= 0xffff:
+		b.WriteByte(maskBit | 126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(maskBit | 127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	b.Write(mask[:])
+	for i, c := range payload {
+		b.WriteByte(c ^ mask[i&3])
+	}
+	return b.Bytes()
+}
+
+// readServerFrame 读取一个服务端帧(不带掩码)
+func readServerFrame(r io.Reader) (*wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[1]&maskBit != 0 {
+		return nil, errors.New("server frame is masked")
+	}
+
+	f := &wsFrame{
+		fin:    header[0]&finBit != 0,
+		rsv1:   header[0]&rsv1Bit != 0,
+		opcode: int(header[0] & 0xf),
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext uint16
+		if err := binary.Read(r, binary.BigEndian, &ext); err != nil {
+			return nil, err
+		}
+		length = uint64(ext)
+	case 127:
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, length)
+	_, err := io.ReadFull(r, f.payload)
+	return f, err
+}
+
+func inflate(t *testing.T, p []byte) []byte {
+	t.Helper()
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+	out, err := ioutil.ReadAll(fr)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatalf("inflate: %v", err)
+	}
+	return out
+}
+
+func closeCode(payload []byte) int {
+	if len(payload) < 2 {
+		return CloseNoStatusReceived
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestWSReadFrame(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 300)
+	huge := bytes.Repeat([]byte("b"), 70000)
+
+	tests := []struct {
+		name      string
+		compress  bool
+		input     []byte
+		opcode    int
+		fin       bool
+		rsv1      bool
+		payload   []byte
+		wantErr   bool
+		closeCode int // 出错时服务端发出的关闭状态码
+	}{
+		{name: "text", input: clientFrame(true, false, TextMessage, []byte("hello")), opcode: TextMessage, fin: true, payload: []byte("hello")},
+		{name: "empty binary", input: clientFrame(true, false, BinaryMessage, nil), opcode: BinaryMessage, fin: true, payload: []byte{}},
+		{name: "16 bit length", input: clientFrame(true, false, BinaryMessage, long), opcode: BinaryMessage, fin: true, payload: long},
+		{name: "64 bit length", input: clientFrame(true, false, BinaryMessage, huge), opcode: BinaryMessage, fin: true, payload: huge},
+		{name: "fragment", input: clientFrame(false, false, TextMessage, []byte("he")), opcode: TextMessage, payload: []byte("he")},
+		{name: "rsv1 with compression", compress: true, input: clientFrame(true, true, TextMessage, []byte("x")), opcode: TextMessage, fin: true, rsv1: true, payload: []byte("x")},
+		{name: "ping", input: clientFrame(true, false, PingMessage, []byte("p")), opcode: PingMessage, fin: true, payload: []byte("p")},
+		{name: "unmasked", input: []byte{finBit | TextMessage, 0}, wantErr: true, closeCode: CloseProtocolError},
+		{name: "rsv2", input: []byte{finBit | rsv2Bit | TextMessage, maskBit, 0, 0, 0, 0}, wantErr: true, closeCode: CloseProtocolError},
+		{name: "rsv1 without compression", input: clientFrame(true, true, TextMessage, []byte("x")), wantErr: true, closeCode: CloseProtocolError},
+		{name: "rsv1 on control frame", compress: true, input: clientFrame(true, true, PingMessage, nil), wantErr: true, closeCode: CloseProtocolError},
+		{name: "fragmented control frame", input: clientFrame(false, false, PingMessage, nil), wantErr: true, closeCode: CloseProtocolError},
+		{name: "control frame too large", input: clientFrame(true, false, PingMessage, long), wantErr: true, closeCode: CloseProtocolError},
+		{name: "truncated payload", input: clientFrame(true, false, TextMessage, []byte("hello"))[:8], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &bufConn{r: bytes.NewReader(tt.input)}
+			ws := newTestWSConn(conn, tt.compress, defaultWSFragmentSize)
+
+			f, err := ws.readFrame()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readFrame() error = nil, want error")
+				}
+				if tt.closeCode == 0 {
+					return
+				}
+				sent, err := readServerFrame(&conn.w)
+				if err != nil || sent.opcode != CloseMessage || closeCode(sent.payload) != tt.closeCode {
+					t.Fatalf("sent close frame %+v, %v, want code %d", sent, err, tt.closeCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFrame() error = %v", err)
+			}
+			if f.opcode != tt.opcode || f.fin != tt.fin || f.rsv1 != tt.rsv1 || !bytes.Equal(f.payload, tt.payload) {
+				t.Fatalf("readFrame() = {fin:%v rsv1:%v opcode:%d len:%d}, want {fin:%v rsv1:%v opcode:%d len:%d}",
+					f.fin, f.rsv1, f.opcode, len(f.payload), tt.fin, tt.rsv1, tt.opcode, len(tt.payload))
+			}
+		})
+	}
+}
+
+func TestWSWriteFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		fin    bool
+		rsv1   bool
+		opcode int
+		size   int
+		header []byte
+	}{
+		{name: "empty", fin: true, opcode: TextMessage, size: 0, header: []byte{0x81, 0}},
+		{name: "7 bit length", fin: true, opcode: BinaryMessage, size: 125, header: []byte{0x82, 125}},
+		{name: "16 bit length min", fin: true, opcode: BinaryMessage, size: 126, header: []byte{0x82, 126, 0, 126}},
+		{name: "16 bit length max", fin: true, opcode: BinaryMessage, size: 0xffff, header: []byte{0x82, 126, 0xff, 0xff}},
+		{name: "64 bit length", fin: true, opcode: BinaryMessage, size: 0x10000, header: []byte{0x82, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+		{name: "not fin", opcode: TextMessage, size: 1, header: []byte{0x01, 1}},
+		{name: "rsv1", fin: true, rsv1: true, opcode: TextMessage, size: 1, header: []byte{0xc1, 1}},
+		{name: "continuation", fin: true, opcode: continuationFrame, size: 1, header: []byte{0x80, 1}},
+		{name: "close", fin: true, opcode: CloseMessage, size: 2, header: []byte{0x88, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &bufConn{r: strings.NewReader("")}
+			ws := newTestWSConn(conn, false, defaultWSFragmentSize)
+			payload := bytes.Repeat([]byte("x"), tt.size)
+
+			if err := ws.writeFrame(tt.fin, tt.rsv1, tt.opcode, payload); err != nil {
+				t.Fatalf("writeFrame() error = %v", err)
+			}
+			out := conn.w.Bytes()
+			if !bytes.Equal(out[:len(tt.header)], tt.header) {
+				t.Fatalf("header = % x, want % x", out[:len(tt.header)], tt.header)
+			}
+			if !bytes.Equal(out[len(tt.header):], payload) {
+				t.Fatalf("payload length = %d, want %d", len(out)-len(tt.header), tt.size)
+			}
+		})
+	}
+}
+
+// wsPipe 返回通过 net.Pipe 连接的服务端 WSConn 和客户端连接
+func wsPipe(t *testing.T, compress bool, fragmentSize int) (*WSConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return newTestWSConn(server, compress, fragmentSize), client
+}
+
+type readResult struct {
+	messageType int
+	p           []byte
+	err         error
+}
+
+func readMessageAsync(ws *WSConn) <-chan readResult {
+	ch := make(chan readResult, 1)
+	go func() {
+		messageType, p, err := ws.ReadMessage()
+		ch <- readResult{messageType, p, err}
+	}()
+	return ch
+}
+
+func TestWSFragmentedMessage(t *testing.T) {
+	ws, client := wsPipe(t, false, 4)
+
+	// 客户端发送分片消息, 中间插入 ping, 服务端应回复 pong 并拼接出完整消息
+	result := readMessageAsync(ws)
+	frames := [][]byte{
+		clientFrame(false, false, TextMessage, []byte("hel")),
+		clientFrame(true, false, PingMessage, []byte("ping")),
+	}
+	for _, f := range frames {
+		if _, err := client.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pong, err := readServerFrame(client)
+	if err != nil || pong.opcode != PongMessage || string(pong.payload) != "ping" {
+		t.Fatalf("pong = %+v, %v", pong, err)
+	}
+	frames = [][]byte{
+		clientFrame(false, false, continuationFrame, []byte("lo ")),
+		clientFrame(true, false, continuationFrame, []byte("world")),
+	}
+	for _, f := range frames {
+		if _, err := client.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := <-result
+	if r.err != nil || r.messageType != TextMessage || string(r.p) != "hello world" {
+		t.Fatalf("ReadMessage() = %d, %q, %v", r.messageType, r.p, r.err)
+	}
+
+	// 服务端按 fragmentSize 分片写出
+	errc := make(chan error, 1)
+	go func() { errc <- ws.WriteMessage(BinaryMessage, []byte("0123456789")) }()
+	var got []byte
+	var opcodes []int
+	for {
+		f, err := readServerFrame(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opcodes = append(opcodes, f.opcode)
+		got = append(got, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	if string(got) != "0123456789" {
+		t.Fatalf("message = %q", got)
+	}
+	want := []int{BinaryMessage, continuationFrame, continuationFrame}
+	if len(opcodes) != len(want) {
+		t.Fatalf("opcodes = %v, want %v", opcodes, want)
+	}
+	for i := range want {
+		if opcodes[i] != want[i] {
+			t.Fatalf("opcodes = %v, want %v", opcodes, want)
+		}
+	}
+}
+
+func TestWSFragmentedMessageErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{name: "continuation without message", frames: [][]byte{clientFrame(true, false, continuationFrame, []byte("x"))}},
+		{name: "data frame inside message", frames: [][]byte{
+			clientFrame(false, false, TextMessage, []byte("a")),
+			clientFrame(true, false, TextMessage, []byte("b")),
+		}},
+		{name: "invalid utf8", frames: [][]byte{
+			clientFrame(false, false, TextMessage, []byte{0xe4, 0xbd}),
+			clientFrame(true, false, continuationFrame, []byte{0xff}),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &bufConn{r: bytes.NewReader(bytes.Join(tt.frames, nil))}
+			ws := newTestWSConn(conn, false, defaultWSFragmentSize)
+			if _, _, err := ws.ReadMessage(); err == nil {
+				t.Fatal("ReadMessage() error = nil, want error")
+			}
+			f, err := readServerFrame(&conn.w)
+			if err != nil || f.opcode != CloseMessage {
+				t.Fatalf("sent frame = %+v, %v, want close", f, err)
+			}
+		})
+	}
+}
+
+func TestWSCompressedMessage(t *testing.T) {
+	ws, client := wsPipe(t, true, 16)
+	message := []byte(strings.Repeat("compressed message ", 20))
+
+	// 客户端发送压缩后分为两片的消息, rsv1 只设置在第一帧
+	compressed, err := compressMessage(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := len(compressed) / 2
+	result := readMessageAsync(ws)
+	frames := [][]byte{
+		clientFrame(false, true, TextMessage, compressed[:half]),
+		clientFrame(true, false, continuationFrame, compressed[half:]),
+	}
+	for _, f := range frames {
+		if _, err := client.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := <-result
+	if r.err != nil || r.messageType != TextMessage || !bytes.Equal(r.p, message) {
+		t.Fatalf("ReadMessage() = %d, %q, %v", r.messageType, r.p, r.err)
+	}
+
+	// 服务端压缩后按 fragmentSize 分片写出
+	errc := make(chan error, 1)
+	go func() { errc <- ws.WriteMessage(TextMessage, message) }()
+	var payload []byte
+	for first := true; ; first = false {
+		f, err := readServerFrame(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.rsv1 != first {
+			t.Fatalf("rsv1 = %v on frame, want %v", f.rsv1, first)
+		}
+		if len(f.payload) > 16 {
+			t.Fatalf("frame payload length = %d, want <= 16", len(f.payload))
+		}
+		payload = append(payload, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	if got := inflate(t, payload); !bytes.Equal(got, message) {
+		t.Fatalf("inflated message = %q, want %q", got, message)
+	}
+}
+
+func TestWSCompressedMessageReadLimit(t *testing.T) {
+	compressed, err := compressMessage(bytes.Repeat([]byte("a"), 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &bufConn{r: bytes.NewReader(clientFrame(true, true, BinaryMessage, compressed))}
+	ws := newTestWSConn(conn, true, defaultWSFragmentSize)
+	ws.SetReadLimit(100)
+
+	if _, _, err := ws.ReadMessage(); err != ErrWSReadLimit {
+		t.Fatalf("ReadMessage() error = %v, want %v", err, ErrWSReadLimit)
+	}
+	f, err := readServerFrame(&conn.w)
+	if err != nil || closeCode(f.payload) != CloseMessageTooBig {
+		t.Fatalf("sent frame = %+v, %v, want close %d", f, err, CloseMessageTooBig)
+	}
+}
+
+func TestWSCloseHandshake(t *testing.T) {
+	t.Run("client initiated", func(t *testing.T) {
+		ws, client := wsPipe(t, false, defaultWSFragmentSize)
+
+		result := readMessageAsync(ws)
+		if _, err := client.Write(clientFrame(true, false, CloseMessage, formatCloseMessage(CloseGoingAway, "bye"))); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := readServerFrame(client)
+		if err != nil || reply.opcode != CloseMessage || closeCode(reply.payload) != CloseGoingAway {
+			t.Fatalf("reply = %+v, %v, want close %d", reply, err, CloseGoingAway)
+		}
+
+		r := <-result
+		var closeErr *WSCloseError
+		if !errors.As(r.err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+			t.Fatalf("ReadMessage() error = %v, want close %d bye", r.err, CloseGoingAway)
+		}
+		if err := ws.WriteMessage(TextMessage, []byte("late")); err != ErrWSCloseSent {
+			t.Fatalf("WriteMessage() after close error = %v, want %v", err, ErrWSCloseSent)
+		}
+	})
+
+	t.Run("server initiated", func(t *testing.T) {
+		ws, client := wsPipe(t, false, defaultWSFragmentSize)
+
+		errc := make(chan error, 1)
+		go func() { errc <- ws.WriteClose(CloseNormalClosure, "done") }()
+		f, err := readServerFrame(client)
+		if err != nil || f.opcode != CloseMessage || closeCode(f.payload) != CloseNormalClosure || string(f.payload[2:]) != "done" {
+			t.Fatalf("close frame = %+v, %v", f, err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("WriteClose() error = %v", err)
+		}
+
+		// 已发送关闭帧, 收到对端的关闭帧时不再回复
+		result := readMessageAsync(ws)
+		if _, err := client.Write(clientFrame(true, false, CloseMessage, formatCloseMessage(CloseNormalClosure, ""))); err != nil {
+			t.Fatal(err)
+		}
+		r := <-result
+		var closeErr *WSCloseError
+		if !errors.As(r.err, &closeErr) || closeErr.Code != CloseNormalClosure {
+			t.Fatalf("ReadMessage() error = %v, want close %d", r.err, CloseNormalClosure)
+		}
+	})
+
+	t.Run("invalid close code", func(t *testing.T) {
+		conn := &bufConn{r: bytes.NewReader(clientFrame(true, false, CloseMessage, formatCloseMessage(1004, "")))}
+		ws := newTestWSConn(conn, false, defaultWSFragmentSize)
+		if _, _, err := ws.ReadMessage(); err == nil {
+			t.Fatal("ReadMessage() error = nil, want protocol error")
+		}
+		f, err := readServerFrame(&conn.w)
+		if err != nil || closeCode(f.payload) != CloseProtocolError {
+			t.Fatalf("sent frame = %+v, %v, want close %d", f, err, CloseProtocolError)
+		}
+	})
+}
+
+func TestComputeAcceptKey(t *testing.T) {
+	// RFC 6455 1.3 中的示例
+	if got, want := computeAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Fatalf("computeAcceptKey() = %q, want %q", got, want)
+	}
+}
+
+func TestWSUpgrade(t *testing.T) {
+	engine := NewServer()
+	engine.WebSocket.Subprotocols = []string{"chat"}
+	engine.WebSocket.EnableCompression = true
+	engine.WS("/ws", func(ws *WSConn) {
+		messageType, p, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		ws.WriteMessage(messageType, p)
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", "other, chat")
+	req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; client_max_window_bits")
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	headers := map[string]string{
+		"Upgrade":                "websocket",
+		"Connection":             "Upgrade",
+		"Sec-WebSocket-Accept":   "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		"Sec-WebSocket-Protocol": "chat",
+	}
+	for name, want := range headers {
+		if got := resp.Header.Get(name); got != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.HasPrefix(got, "permessage-deflate") {
+		t.Fatalf("Sec-WebSocket-Extensions = %q, want permessage-deflate", got)
+	}
+
+	// 握手后的连接可以正常收发消息
+	if _, err = conn.Write(clientFrame(true, false, TextMessage, []byte("echo"))); err != nil {
+		t.Fatal(err)
+	}
+	f, err := readServerFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := f.payload
+	if f.rsv1 {
+		payload = inflate(t, payload)
+	}
+	if f.opcode != TextMessage || string(payload) != "echo" {
+		t.Fatalf("echo frame = %d %q", f.opcode, payload)
+	}
+}
+
+func TestWSUpgradeBadHandshake(t *testing.T) {
+	engine := NewServer()
+	engine.WS("/ws", func(ws *WSConn) {})
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		code   int
+	}{
+		{name: "not upgrade", header: map[string]string{"Sec-WebSocket-Version": "13"}, code: http.StatusBadRequest},
+		{name: "bad version", header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8"}, code: http.StatusUpgradeRequired},
+		{name: "bad key", header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}, code: http.StatusBadRequest},
+		{name: "cross origin", header: map[string]string{
+			"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13",
+			"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "http://evil.example",
+		}, code: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}